@@ -3,7 +3,6 @@ module github.com/Drelf2018/exp/drink
 go 1.23
 
 require (
-	github.com/Drelf2018/dingtalk v0.0.0-20260119185921-eb58aad0f621
 	github.com/Drelf2018/exp/hook v0.0.0-20260124144020-ee0e790709c3
 	github.com/Drelf2018/go-bilibili-api v0.0.0-20260126130458-c368e8ab46ff
 	github.com/Drelf2018/go-bilibili-api/cookie v0.0.0-20260126130458-c368e8ab46ff
@@ -16,20 +15,32 @@ require (
 )
 
 require (
+	github.com/Drelf2018/dingtalk v0.0.0-20260119185921-eb58aad0f621 // indirect
 	github.com/antonfisher/nested-logrus-formatter v1.3.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/glebarez/go-sqlite v1.21.2 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_golang v1.20.5 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/writeas/go-strip-markdown v2.0.1+incompatible // indirect
-	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/libc v1.22.5 // indirect
 	modernc.org/mathutil v1.5.0 // indirect
 	modernc.org/memory v1.5.0 // indirect
 	modernc.org/sqlite v1.23.1 // indirect
 )
+
+replace github.com/Drelf2018/exp/hook => ../hook
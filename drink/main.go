@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Drelf2018/dingtalk"
 	"github.com/Drelf2018/exp/hook"
 	bilibili "github.com/Drelf2018/go-bilibili-api"
 	"github.com/Drelf2018/go-bilibili-api/cookie"
@@ -23,12 +22,12 @@ import (
 )
 
 type Options struct {
-	Sleep    int           `long:"sleep" description:"休眠小时"`
-	Drinks   string        `long:"drinks" description:"饮料文件路径"`
-	Logger   string        `long:"logger" description:"日志文件路径"`
-	QRCode   string        `long:"qrcode" description:"扫码登录文件路径"`
-	Database string        `long:"database" description:"数据库文件路径"`
-	DingTalk *dingtalk.Bot `group:"DingTalk" description:"钉钉机器人"`
+	Sleep    int         `long:"sleep" description:"休眠小时"`
+	Drinks   string      `long:"drinks" description:"饮料文件路径"`
+	QRCode   string      `long:"qrcode" description:"扫码登录文件路径"`
+	Database string      `long:"database" description:"数据库文件路径"`
+	Metrics  string      `long:"metrics" description:"指标服务监听地址，为空时不启用"`
+	Logger   hook.Config `group:"Logger" description:"日志配置"`
 }
 
 type Drink struct {
@@ -42,6 +41,7 @@ var (
 	options Options
 	logger  *logrus.Logger
 	bot     *logrus.Entry
+	crash   *hook.CrashReporter
 	jar     http.CookieJar
 	db      *gorm.DB
 )
@@ -59,13 +59,24 @@ func init() {
 		logrus.Panic(err)
 	}
 	// 初始化日志
-	ding := hook.NewDingTalkHook(options.DingTalk)
-	logger = hook.New(logrus.InfoLevel, hook.NewDailyFileHook(options.Logger), ding)
-	bot = ding.Bind(logger)
+	logger, bot, err = options.Logger.New()
+	if err != nil {
+		logrus.Panic(err)
+	}
+	crash = hook.NewCrashReporter(bot, 20)
+	// 开启指标服务
+	if options.Metrics != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", hook.MetricsHandler())
+			logger.Errorln("指标服务退出:", http.ListenAndServe(options.Metrics, mux))
+		}()
+	}
 }
 
 // 初始化数据库
 func init() {
+	defer crash.Recover()
 	var err error
 	logger.Info("初始化数据库")
 	db, err = gorm.Open(sqlite.Open(options.Database))
@@ -103,6 +114,7 @@ func init() {
 
 // 扫码登录
 func init() {
+	defer crash.Recover()
 	if options.QRCode != "" {
 		logger.Info("扫码登录")
 		generate, err := bilibili.GetGenerate(context.Background())
@@ -133,6 +145,7 @@ func init() {
 
 // 初始化 Cookie
 func init() {
+	defer crash.Recover()
 	logger.Info("初始化 Cookie")
 	r := &cookie.Refresher{}
 	err := db.Preload("Cookies").Order("id DESC").First(r).Error
@@ -248,6 +261,7 @@ func GetMyGoldWallet(ctx context.Context, jar http.CookieJar) (MyGoldWalletRespo
 }
 
 func main() {
+	defer crash.Recover()
 	logger.Info("开始轮询")
 	// 随机轮询计时器
 	ticker := req.NewTicker(req.RandomTicker{4 * time.Minute, 6 * time.Minute})
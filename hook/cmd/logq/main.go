@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/Drelf2018/exp/hook"
+	"github.com/jessevdk/go-flags"
+	"github.com/sirupsen/logrus"
+)
+
+type Options struct {
+	Layout string   `short:"l" long:"layout" required:"true" description:"日志文件路径模板，例如 logs/2006-01-02.log"`
+	Since  string   `short:"s" long:"since" description:"起始时间，格式为 2006-01-02 或 2006-01-02 15:04:05 ，默认为截止时间前 30 天"`
+	Until  string   `short:"u" long:"until" description:"截止时间，格式同上，默认为当前时间"`
+	Level  []string `short:"L" long:"level" description:"日志等级，可以重复指定"`
+	Title  string   `short:"t" long:"title" description:"标题字段"`
+	Regexp string   `short:"e" long:"regexp" description:"匹配日志原文的正则表达式"`
+}
+
+// parseTime 解析日期或日期时间，仅有日期时截止时间取当天结束
+func parseTime(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateTime, s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return t, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+func main() {
+	var options Options
+	_, err := flags.Parse(&options)
+	if err != nil {
+		// 显示帮助信息不是错误
+		if flags.WroteHelp(err) {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+	q := &hook.Query{Title: options.Title}
+	q.Since, err = parseTime(options.Since, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "解析起始时间失败:", err)
+		os.Exit(1)
+	}
+	q.Until, err = parseTime(options.Until, true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "解析截止时间失败:", err)
+		os.Exit(1)
+	}
+	for _, l := range options.Level {
+		level, err := logrus.ParseLevel(l)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "解析日志等级失败:", err)
+			os.Exit(1)
+		}
+		q.Levels = append(q.Levels, level)
+	}
+	if options.Regexp != "" {
+		q.Pattern, err = regexp.Compile(options.Regexp)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "解析正则表达式失败:", err)
+			os.Exit(1)
+		}
+	}
+	records, err := q.Run(options.Layout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "查询日志失败:", err)
+		os.Exit(1)
+	}
+	for _, r := range records {
+		fmt.Println(r)
+	}
+}
@@ -0,0 +1,306 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/Drelf2018/dingtalk"
+	"github.com/sirupsen/logrus"
+)
+
+// ParseLevels 解析日志等级，返回不高于该等级的全部等级，字符串为空时返回空切片，即视为全部等级
+func ParseLevels(level string) ([]logrus.Level, error) {
+	if level == "" {
+		return nil, nil
+	}
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return logrus.AllLevels[:lvl+1], nil
+}
+
+// NewFormatter 根据名称创建格式化器，可选值为 nested 、 text 和 json ，为空时使用 nested
+func NewFormatter(name string) (logrus.Formatter, error) {
+	hideCaller := func(*runtime.Frame) (string, string) { return "", "" }
+	switch name {
+	case "", "nested":
+		return NestedFormatter(), nil
+	case "text":
+		return &logrus.TextFormatter{
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  "2006-01-02 15:04:05",
+			CallerPrettyfier: hideCaller,
+		}, nil
+	case "json":
+		return &logrus.JSONFormatter{
+			TimestampFormat:  "2006-01-02 15:04:05",
+			CallerPrettyfier: hideCaller,
+		}, nil
+	default:
+		return nil, fmt.Errorf("hook: unknown formatter: %s", name)
+	}
+}
+
+// Config 日志配置，字段标签兼容 go-flags 的命令行参数和 ini 配置文件，例如：
+//
+//	[Logger]
+//	level = info
+//	formatter = nested
+//	console = split
+//	color = auto
+//	logger = logs/2006-01-02.log
+//	escalation = 10m
+//
+//	[DingTalk]
+//	name = bot
+//	token = xxx
+//
+//	[Lark]
+//	name = lark
+//	url = https://open.feishu.cn/open-apis/bot/v2/hook/xxx
+//
+//	[Email]
+//	name = mail
+//	host = smtp.example.com
+//	port = 587
+//	from = bot@example.com
+//	to = admin@example.com
+//	interval = 1m
+//
+// go-flags 会在全部分组中查找没有小节的选项，因此旧配置文件中顶层的 logger = xxx 和 [DingTalk] 小节仍然有效，无需迁移
+type Config struct {
+	// 控制台日志等级
+	Level string `long:"level" default:"info" description:"控制台日志等级"`
+
+	// 格式化器
+	Formatter string `long:"formatter" default:"nested" choice:"nested" choice:"text" choice:"json" description:"日志格式"`
+
+	// 控制台输出，可选值为 stderr 、 stdout 、 split 和 none ，其中 split 表示按分流等级分别写入标准错误和标准输出
+	Console string `long:"console" default:"stderr" choice:"stderr" choice:"stdout" choice:"split" choice:"none" description:"控制台输出"`
+
+	// 控制台分流等级，不低于该等级的日志写入标准错误
+	SplitLevel string `long:"split-level" default:"warning" description:"控制台分流等级"`
+
+	// 控制台颜色，可选值为 auto 、 always 和 never ，其中 auto 表示仅在终端中使用颜色
+	Color string `long:"color" default:"auto" choice:"auto" choice:"always" choice:"never" description:"控制台颜色"`
+
+	// 日志文件路径模板，为空时不写入文件
+	File string `short:"l" long:"logger" description:"日志文件路径"`
+
+	// 日志文件等级，为空时视为全部等级
+	FileLevel string `long:"file-level" description:"日志文件等级"`
+
+	// 日志文件是否以 JSON 行格式写入
+	FileJSON bool `long:"file-json" description:"日志文件使用 JSON 格式"`
+
+	// 钉钉机器人，令牌为空时不启用
+	DingTalk *dingtalk.Bot `group:"DingTalk" description:"钉钉机器人"`
+
+	// 钉钉机器人日志等级，为空时视为全部等级
+	DingTalkLevel string `long:"dingtalk-level" description:"钉钉机器人日志等级"`
+
+	// 飞书机器人，推送地址为空时不启用
+	Lark *WebhookBot `group:"Lark" namespace:"lark" description:"飞书机器人"`
+
+	// 飞书机器人日志等级，为空时视为全部等级
+	LarkLevel string `long:"lark-level" description:"飞书机器人日志等级"`
+
+	// 企业微信机器人，推送地址为空时不启用
+	WeCom *WebhookBot `group:"WeCom" namespace:"wecom" description:"企业微信机器人"`
+
+	// 企业微信机器人日志等级，为空时视为全部等级
+	WeComLevel string `long:"wecom-level" description:"企业微信机器人日志等级"`
+
+	// 邮件机器人，服务器地址为空时不启用
+	Email *EmailBot `group:"Email" namespace:"email" description:"邮件机器人"`
+
+	// 邮件机器人日志等级，为空时视为全部等级
+	EmailLevel string `long:"email-level" description:"邮件机器人日志等级"`
+
+	// 同一通知的统计窗口，值为正时对带有 Escalate 字段的通知启用去重升级
+	Escalation time.Duration `long:"escalation" default:"10m" description:"同一通知的统计窗口"`
+
+	// 窗口内出现次数阈值，为 0 时第一次出现就通知
+	Threshold int `long:"threshold" default:"0" description:"同一通知在窗口内出现超过多少次后发送"`
+
+	// 默认绑定的机器人名称，为空时依次选择钉钉、飞书、企业微信和邮件中第一个启用的机器人
+	Bot string `long:"bot" description:"默认绑定的机器人名称"`
+}
+
+// ColorFormatter 根据名称创建带颜色的格式化器，不支持颜色的格式化器返回空
+func ColorFormatter(name string) logrus.Formatter {
+	switch name {
+	case "", "nested":
+		return ColoredNestedFormatter()
+	case "text":
+		return &logrus.TextFormatter{
+			ForceColors:      true,
+			FullTimestamp:    true,
+			TimestampFormat:  "2006-01-02 15:04:05",
+			CallerPrettyfier: func(*runtime.Frame) (string, string) { return "", "" },
+		}
+	default:
+		return nil
+	}
+}
+
+// ConsoleHook 根据配置创建控制台钩子，不输出到控制台时返回空
+func (c *Config) ConsoleHook(level logrus.Level) (logrus.Hook, error) {
+	levels := logrus.AllLevels[:level+1]
+	var hook *WriterHook
+	switch c.Console {
+	case "", "stderr":
+		hook = NewWriterHook(os.Stderr, levels...)
+	case "stdout":
+		hook = NewWriterHook(os.Stdout, levels...)
+	case "split":
+		split := logrus.WarnLevel
+		if c.SplitLevel != "" {
+			var err error
+			split, err = logrus.ParseLevel(c.SplitLevel)
+			if err != nil {
+				return nil, err
+			}
+		}
+		hook = NewSplitHook(split, levels...)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("hook: unknown console: %s", c.Console)
+	}
+	switch c.Color {
+	case "", "auto":
+		hook.Colorize(ColorFormatter(c.Formatter))
+	case "always":
+		hook.Formatter = ColorFormatter(c.Formatter)
+	case "never":
+	default:
+		return nil, fmt.Errorf("hook: unknown color: %s", c.Color)
+	}
+	return hook, nil
+}
+
+// notify 为通知钩子添加去重升级
+func (c *Config) notify(hook logrus.Hook) logrus.Hook {
+	if c.Escalation > 0 {
+		return NewEscalationHook(hook, c.Escalation, c.Threshold)
+	}
+	return hook
+}
+
+// Hooks 根据配置创建全部钩子，不包括控制台钩子
+func (c *Config) Hooks() ([]logrus.Hook, error) {
+	var hooks []logrus.Hook
+	if c.File != "" {
+		levels, err := ParseLevels(c.FileLevel)
+		if err != nil {
+			return nil, err
+		}
+		if c.FileJSON {
+			hooks = append(hooks, NewDailyJSONFileHook(c.File, levels...))
+		} else {
+			hooks = append(hooks, NewDailyFileHook(c.File, levels...))
+		}
+	}
+	if c.DingTalk != nil && c.DingTalk.Token != "" {
+		levels, err := ParseLevels(c.DingTalkLevel)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, c.notify(NewDingTalkHook(c.DingTalk, levels...)))
+	}
+	if c.Lark != nil && c.Lark.URL != "" {
+		levels, err := ParseLevels(c.LarkLevel)
+		if err != nil {
+			return nil, err
+		}
+		c.Lark.Signer = LarkSign
+		err = c.Lark.Parse(LarkBody, LarkText)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, c.notify(NewWebhookHook(c.Lark, levels...)))
+	}
+	if c.WeCom != nil && c.WeCom.URL != "" {
+		levels, err := ParseLevels(c.WeComLevel)
+		if err != nil {
+			return nil, err
+		}
+		err = c.WeCom.Parse(WeComBody, WeComText)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, c.notify(NewWebhookHook(c.WeCom, levels...)))
+	}
+	if c.Email != nil && c.Email.Host != "" {
+		levels, err := ParseLevels(c.EmailLevel)
+		if err != nil {
+			return nil, err
+		}
+		err = c.Email.Parse(EmailSubject, EmailText, EmailHTML)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, c.notify(NewEmailHook(c.Email, levels...)))
+	}
+	return hooks, nil
+}
+
+// Lookup 查找指定名称的已启用机器人，返回绑定时使用的键和机器人名称，名称为空时返回第一个启用的机器人
+func (c *Config) Lookup(name string) (key string, bot string, ok bool) {
+	if c.DingTalk != nil && c.DingTalk.Token != "" && (name == "" || name == c.DingTalk.Name) {
+		return DingTalk, c.DingTalk.Name, true
+	}
+	for _, bot := range []*WebhookBot{c.Lark, c.WeCom} {
+		if bot != nil && bot.URL != "" && (name == "" || name == bot.Name) {
+			return Webhook, bot.Name, true
+		}
+	}
+	if c.Email != nil && c.Email.Host != "" && (name == "" || name == c.Email.Name) {
+		return Email, c.Email.Name, true
+	}
+	return "", "", false
+}
+
+// Bind 将指定名称的机器人绑定在日志上，名称为空时使用默认绑定的机器人，找不到机器人时返回不绑定的日志
+func (c *Config) Bind(logger *logrus.Logger, name string) *logrus.Entry {
+	if name == "" {
+		name = c.Bot
+	}
+	key, bot, ok := c.Lookup(name)
+	if !ok {
+		return logrus.NewEntry(logger)
+	}
+	return logger.WithField(key, bot)
+}
+
+// New 根据配置创建日志，并返回绑定了默认机器人的日志
+func (c *Config) New() (*logrus.Logger, *logrus.Entry, error) {
+	level := logrus.InfoLevel
+	if c.Level != "" {
+		var err error
+		level, err = logrus.ParseLevel(c.Level)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	formatter, err := NewFormatter(c.Formatter)
+	if err != nil {
+		return nil, nil, err
+	}
+	console, err := c.ConsoleHook(level)
+	if err != nil {
+		return nil, nil, err
+	}
+	hooks, err := c.Hooks()
+	if err != nil {
+		return nil, nil, err
+	}
+	logger := NewWithConsole(console, hooks...)
+	logger.Formatter = formatter
+	return logger, c.Bind(logger, ""), nil
+}
@@ -0,0 +1,80 @@
+package hook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+type testOptions struct {
+	Me     int    `long:"me"`
+	Logger Config `group:"Logger"`
+}
+
+func parseIni(t *testing.T, ini string) *testOptions {
+	t.Helper()
+	var opts testOptions
+	err := flags.NewIniParser(flags.NewParser(&opts, flags.Default)).Parse(strings.NewReader(ini))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &opts
+}
+
+func TestConfigIni(t *testing.T) {
+	opts := parseIni(t, `
+[Logger]
+level = debug
+logger = logs/2006-01-02.log
+escalation = 10m
+
+[DingTalk]
+name = bot
+token = xxx
+
+[Lark]
+name = lark
+url = https://open.feishu.cn/open-apis/bot/v2/hook/xxx
+timeout = 3s
+
+[Email]
+name = mail
+host = smtp.example.com
+port = 587
+to = a@example.com
+to = b@example.com
+interval = 1m
+`)
+	c := opts.Logger
+	if c.Level != "debug" || c.File != "logs/2006-01-02.log" || c.Escalation != 10*time.Minute {
+		t.Errorf("unexpected logger config: %+v", c)
+	}
+	if c.DingTalk == nil || c.DingTalk.Name != "bot" || c.DingTalk.Token != "xxx" {
+		t.Errorf("unexpected dingtalk: %+v", c.DingTalk)
+	}
+	if c.Lark == nil || c.Lark.Name != "lark" || c.Lark.Timeout != 3*time.Second {
+		t.Errorf("unexpected lark: %+v", c.Lark)
+	}
+	if c.Email == nil || c.Email.Port != 587 || len(c.Email.To) != 2 || c.Email.Interval != time.Minute {
+		t.Errorf("unexpected email: %+v", c.Email)
+	}
+}
+
+func TestConfigLegacyIni(t *testing.T) {
+	opts := parseIni(t, `
+me = 1
+logger = logs/2006-01-02.log
+
+[DingTalk]
+name = bot
+token = xxx
+`)
+	if opts.Me != 1 || opts.Logger.File != "logs/2006-01-02.log" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if key, bot, ok := opts.Logger.Lookup(""); !ok || key != DingTalk || bot != "bot" {
+		t.Errorf("lookup = %s %s %v", key, bot, ok)
+	}
+}
@@ -1,7 +1,9 @@
 package hook
 
 import (
+	"io"
 	"os"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -21,3 +23,81 @@ func (ConsoleHook) Fire(entry *logrus.Entry) error {
 	_, err = os.Stderr.Write(b)
 	return err
 }
+
+// IsTerminal 判断写入器是否为终端，设置了 NO_COLOR 环境变量时视为不是终端
+func IsTerminal(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// WriterHook 可配置的控制台钩子，可以将严重的日志分流到另一个写入器
+type WriterHook struct {
+	Out       io.Writer        // 日志输出
+	Err       io.Writer        // 不低于分流等级的日志输出，为空时使用 Out
+	Split     logrus.Level     // 分流等级，例如 WarnLevel 表示警告及更严重的日志写入 Err
+	Formatter logrus.Formatter // 格式化器，为空时使用日志自身的格式化器
+	mu        sync.Mutex       // 写入锁
+	levels    []logrus.Level   // 日志等级，为空时视为全部等级
+}
+
+func (w *WriterHook) Levels() []logrus.Level {
+	if len(w.levels) != 0 {
+		return w.levels
+	}
+	return logrus.AllLevels
+}
+
+// writer 获取日志事件对应的写入器
+func (w *WriterHook) writer(level logrus.Level) io.Writer {
+	if w.Err != nil && level <= w.Split {
+		return w.Err
+	}
+	return w.Out
+}
+
+func (w *WriterHook) Fire(entry *logrus.Entry) error {
+	var b []byte
+	var err error
+	if w.Formatter != nil {
+		b, err = w.Formatter.Format(entry)
+	} else {
+		b, err = entry.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.writer(entry.Level).Write(b)
+	return err
+}
+
+var _ logrus.Hook = (*WriterHook)(nil)
+
+// Colorize 写入器均为终端时使用带颜色的格式化器，格式化器为空时不修改
+func (w *WriterHook) Colorize(formatter logrus.Formatter) *WriterHook {
+	if formatter != nil && IsTerminal(w.Out) && (w.Err == nil || IsTerminal(w.Err)) {
+		w.Formatter = formatter
+	}
+	return w
+}
+
+// NewWriterHook 创建写入指定写入器的钩子，日志等级为空时视为全部等级
+func NewWriterHook(out io.Writer, levels ...logrus.Level) *WriterHook {
+	return &WriterHook{Out: out, levels: levels}
+}
+
+// NewSplitHook 创建分流钩子，不低于分流等级的日志写入 os.Stderr ，其余写入 os.Stdout ，日志等级为空时视为全部等级
+func NewSplitHook(split logrus.Level, levels ...logrus.Level) *WriterHook {
+	return &WriterHook{Out: os.Stdout, Err: os.Stderr, Split: split, levels: levels}
+}
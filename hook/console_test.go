@@ -0,0 +1,87 @@
+package hook
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSplitHook(t *testing.T) {
+	h := NewSplitHook(logrus.WarnLevel)
+	if h.Out != os.Stdout || h.Err != os.Stderr {
+		t.Fatal("split hook does not write to stdout and stderr")
+	}
+	var out, errOut bytes.Buffer
+	h.Out, h.Err = &out, &errOut
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+	logger.SetLevel(logrus.DebugLevel)
+	logger.AddHook(h)
+	logger.Debug("调试")
+	logger.Info("信息")
+	logger.Warn("警告")
+	logger.Error("错误")
+	for _, c := range []struct {
+		buf       *bytes.Buffer
+		has, miss []string
+	}{
+		{&out, []string{"调试", "信息"}, []string{"警告", "错误"}},
+		{&errOut, []string{"警告", "错误"}, []string{"调试", "信息"}},
+	} {
+		for _, s := range c.has {
+			if !strings.Contains(c.buf.String(), s) {
+				t.Errorf("%q missing in %q", s, c.buf.String())
+			}
+		}
+		for _, s := range c.miss {
+			if strings.Contains(c.buf.String(), s) {
+				t.Errorf("%q should not be in %q", s, c.buf.String())
+			}
+		}
+	}
+	// 未设置 Err 时全部写入 Out
+	out.Reset()
+	h.Err = nil
+	logger.Error("错误")
+	if !strings.Contains(out.String(), "错误") {
+		t.Errorf("out = %q", out.String())
+	}
+}
+
+func TestColorize(t *testing.T) {
+	// /dev/null 是字符设备，视为终端
+	tty, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tty.Close()
+	if info, err := tty.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		t.Skip("null device is not a character device")
+	}
+	t.Setenv("NO_COLOR", "")
+	os.Unsetenv("NO_COLOR")
+	color := &logrus.TextFormatter{ForceColors: true}
+	if !IsTerminal(tty) || IsTerminal(&bytes.Buffer{}) {
+		t.Error("IsTerminal does not detect character devices")
+	}
+	if h := NewWriterHook(tty).Colorize(color); h.Formatter != color {
+		t.Error("terminal writer was not colorized")
+	}
+	if h := (&WriterHook{Out: tty, Err: &bytes.Buffer{}}).Colorize(color); h.Formatter != nil {
+		t.Error("colorized when stderr is not a terminal")
+	}
+	if h := NewWriterHook(tty).Colorize(nil); h.Formatter != nil {
+		t.Error("nil formatter replaced")
+	}
+	// 设置 NO_COLOR 后，即使为空值也不使用颜色
+	t.Setenv("NO_COLOR", "")
+	if IsTerminal(tty) {
+		t.Error("NO_COLOR is ignored")
+	}
+	if h := NewWriterHook(tty).Colorize(color); h.Formatter != nil {
+		t.Error("colorized with NO_COLOR set")
+	}
+}
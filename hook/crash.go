@@ -0,0 +1,251 @@
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Waiter 可以等待异步发送完成的钩子
+type Waiter interface {
+	Wait()
+}
+
+// inflight 正在异步发送的消息计数，与 sync.WaitGroup 不同，等待时可以并发地开始新的发送
+type inflight struct {
+	mu    sync.Mutex
+	count int
+	idle  chan struct{} // 计数归零时关闭
+}
+
+// Add 开始一次发送
+func (f *inflight) Add() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.count++
+}
+
+// Done 完成一次发送
+func (f *inflight) Done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count--
+	if f.count == 0 {
+		close(f.idle)
+	}
+}
+
+// Wait 等待计数归零，等待期间开始的发送也会被等待
+func (f *inflight) Wait() {
+	f.mu.Lock()
+	if f.count == 0 {
+		f.mu.Unlock()
+		return
+	}
+	idle := f.idle
+	f.mu.Unlock()
+	<-idle
+}
+
+// forwardHook 交换钩子期间将日志事件转发给原有钩子的副本
+type forwardHook struct {
+	ready chan struct{} // 副本准备好后关闭
+	hooks logrus.LevelHooks
+}
+
+func (*forwardHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (f *forwardHook) Fire(entry *logrus.Entry) error {
+	<-f.ready
+	return f.hooks.Fire(entry.Level, entry)
+}
+
+// copyHooks 通过 ReplaceHooks 在日志锁内取出钩子并复制，避免与 AddHook 并发读写，取出期间的日志事件仍会交给原有钩子
+func copyHooks(logger *logrus.Logger) logrus.LevelHooks {
+	forward := &forwardHook{ready: make(chan struct{})}
+	swap := make(logrus.LevelHooks)
+	swap.Add(forward)
+	hooks := logger.ReplaceHooks(swap)
+	forward.hooks = make(logrus.LevelHooks, len(hooks))
+	for level, levelHooks := range hooks {
+		forward.hooks[level] = append([]logrus.Hook(nil), levelHooks...)
+	}
+	close(forward.ready)
+	// 放回钩子，取出期间添加的钩子在其第一个等级中只出现一次，据此重新添加
+	for level, added := range logger.ReplaceHooks(hooks) {
+		for _, hook := range added {
+			if hook == logrus.Hook(forward) {
+				continue
+			}
+			if levels := hook.Levels(); len(levels) != 0 && levels[0] == level {
+				logger.AddHook(hook)
+			}
+		}
+	}
+	return forward.hooks
+}
+
+// WaitHooks 等待日志中全部钩子的异步发送完成，超时后直接返回假
+func WaitHooks(logger *logrus.Logger, timeout time.Duration) bool {
+	// 同一钩子可能出现在多个等级中，重复等待不影响结果
+	waiters := make([]Waiter, 0)
+	for _, hooks := range copyHooks(logger) {
+		for _, hook := range hooks {
+			if waiter, ok := hook.(Waiter); ok {
+				waiters = append(waiters, waiter)
+			}
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, waiter := range waiters {
+			waiter.Wait()
+		}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// RingHook 保存最近若干条日志的钩子
+type RingHook struct {
+	mu      sync.Mutex
+	entries []string
+	next    int
+	full    bool
+	levels  []logrus.Level // 日志等级，为空时视为全部等级
+}
+
+func (r *RingHook) Levels() []logrus.Level {
+	if len(r.levels) != 0 {
+		return r.levels
+	}
+	return logrus.AllLevels
+}
+
+func (r *RingHook) Fire(entry *logrus.Entry) error {
+	b, err := entry.Bytes()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = strings.TrimRight(string(b), "\n")
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+var _ logrus.Hook = (*RingHook)(nil)
+
+// Entries 按时间顺序获取最近的日志
+func (r *RingHook) Entries() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string{}, r.entries[:r.next]...)
+	}
+	return append(append([]string{}, r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// NewRingHook 创建保存最近 size 条日志的钩子，日志等级为空时视为全部等级
+func NewRingHook(size int, levels ...logrus.Level) *RingHook {
+	if size <= 0 {
+		size = 1
+	}
+	return &RingHook{entries: make([]string, size), levels: levels}
+}
+
+// GoroutineID 从调用栈中解析协程序号
+func GoroutineID(stack []byte) int {
+	line, _, _ := bytes.Cut(stack, []byte("\n"))
+	field := bytes.Fields(line)
+	if len(field) < 2 || string(field[0]) != "goroutine" {
+		return 0
+	}
+	id, _ := strconv.Atoi(string(field[1]))
+	return id
+}
+
+// CrashReporter 崩溃报告器，恢复恐慌后通过绑定的通知钩子发送崩溃报告，然后退出程序
+type CrashReporter struct {
+	Entry   *logrus.Entry  // 绑定了通知钩子的日志
+	Recent  *RingHook      // 最近的日志
+	Timeout time.Duration  // 等待通知发送完成的最长时间
+	Exit    func(code int) // 退出程序的函数，为空时使用 os.Exit
+}
+
+// Report 发送崩溃报告并等待通知发送完成
+func (c *CrashReporter) Report(v any, stack []byte) {
+	// logrus 的 Panic 系列方法会以日志事件作为恐慌值
+	var message string
+	if entry, ok := v.(*logrus.Entry); ok {
+		message = entry.Message
+	} else {
+		message = fmt.Sprint(v)
+	}
+	b := &strings.Builder{}
+	b.WriteString(message)
+	if c.Recent != nil {
+		if recent := c.Recent.Entries(); len(recent) != 0 {
+			b.WriteString("\n\n最近日志:\n")
+			b.WriteString(strings.Join(recent, "\n"))
+		}
+	}
+	b.WriteString("\n\n调用栈:\n")
+	b.Write(bytes.TrimSpace(stack))
+	c.Entry.WithFields(logrus.Fields{
+		"title":     "程序崩溃",
+		"goroutine": GoroutineID(stack),
+	}).Error(b.String())
+	if !WaitHooks(c.Entry.Logger, c.Timeout) {
+		fmt.Fprintln(os.Stderr, "hook: timed out waiting for crash report")
+	}
+}
+
+// Recover 恢复恐慌并发送崩溃报告，必须直接使用 defer 调用
+func (c *CrashReporter) Recover() {
+	if v := recover(); v != nil {
+		c.Report(v, debug.Stack())
+		exit := c.Exit
+		if exit == nil {
+			exit = os.Exit
+		}
+		exit(2)
+	}
+}
+
+// Go 在新协程中运行函数，发生恐慌时发送崩溃报告
+func (c *CrashReporter) Go(f func()) {
+	go func() {
+		defer c.Recover()
+		f()
+	}()
+}
+
+// NewCrashReporter 创建崩溃报告器，会在日志上添加保存最近 size 条日志的钩子
+func NewCrashReporter(entry *logrus.Entry, size int) *CrashReporter {
+	recent := NewRingHook(size)
+	entry.Logger.AddHook(recent)
+	return &CrashReporter{Entry: entry, Recent: recent, Timeout: 10 * time.Second}
+}
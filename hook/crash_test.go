@@ -0,0 +1,132 @@
+package hook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestInflight(t *testing.T) {
+	var f inflight
+	f.Wait() // 没有发送时立即返回
+
+	f.Add()
+	waited := make(chan struct{})
+	go func() {
+		f.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("wait returned before done")
+	case <-time.After(20 * time.Millisecond):
+	}
+	f.Done()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return after done")
+	}
+}
+
+func TestWaitHooksWhileFiring(t *testing.T) {
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		received.Add(1)
+	}))
+	defer srv.Close()
+	bot := &WebhookBot{Name: "test", URL: srv.URL}
+	if err := bot.Parse(`{"text":{{json .Text}}}`, "{{.Message}}"); err != nil {
+		t.Fatal(err)
+	}
+	hook := NewWebhookHook(bot)
+	logger := NewWithConsole(nil, hook)
+	entry := hook.Bind(logger)
+
+	// 崩溃时其他协程可能仍在写日志，等待和发送并发进行
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				entry.Error("boom")
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if !WaitHooks(logger, 5*time.Second) {
+			t.Fatal("timed out waiting for hooks")
+		}
+	}
+	wg.Wait()
+	if !WaitHooks(logger, 5*time.Second) {
+		t.Fatal("timed out waiting for hooks")
+	}
+	if got := received.Load(); got != 160 {
+		t.Errorf("received %d messages, want 160", got)
+	}
+}
+
+func TestWaitHooksWhileAdding(t *testing.T) {
+	logger := NewWithConsole(nil)
+	var fired atomic.Int64
+	counter := &funcHook{fire: func() { fired.Add(1) }}
+	logger.AddHook(counter)
+	// 等待时并发地添加钩子和写日志，运行 go test -race 检查
+	var wg sync.WaitGroup
+	added := make([]*funcHook, 50)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range added {
+			added[i] = &funcHook{fire: func() {}}
+			logger.AddHook(added[i])
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			logger.Error("boom")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		WaitHooks(logger, time.Second)
+	}
+	wg.Wait()
+	// 交换钩子期间的日志不会丢失，添加的钩子各出现一次
+	if got := fired.Load(); got != 200 {
+		t.Errorf("fired %d times, want 200", got)
+	}
+	count := make(map[*funcHook]int)
+	for _, hook := range logger.Hooks[logrus.ErrorLevel] {
+		if h, ok := hook.(*funcHook); ok {
+			count[h]++
+		}
+	}
+	for i, h := range added {
+		if count[h] != 1 {
+			t.Errorf("hook %d added %d times", i, count[h])
+		}
+	}
+	if count[counter] != 1 {
+		t.Errorf("original hook present %d times", count[counter])
+	}
+}
+
+// funcHook 触发时调用函数的钩子
+type funcHook struct{ fire func() }
+
+func (*funcHook) Levels() []logrus.Level { return []logrus.Level{logrus.ErrorLevel, logrus.WarnLevel} }
+
+func (f *funcHook) Fire(*logrus.Entry) error {
+	f.fire()
+	return nil
+}
@@ -18,11 +18,12 @@ func IsSameDay(t1, t2 time.Time) bool {
 
 // DailyFileHook 以本地时区的日期为单位将日志写入文件的钩子
 type DailyFileHook struct {
-	Layout string         // 日志文件路径模板，会利用日志事件的时间进行格式化处理，参考值 "logs/2006-01-02.log"
-	mu     sync.Mutex     // 日志锁
-	file   *os.File       // 日志文件
-	date   time.Time      // 日志文件的创建时间
-	levels []logrus.Level // 日志等级，为空时视为全部等级
+	Layout    string           // 日志文件路径模板，会利用日志事件的时间进行格式化处理，参考值 "logs/2006-01-02.log"
+	Formatter logrus.Formatter // 格式化器，为空时使用日志自身的格式化器
+	mu        sync.Mutex       // 日志锁
+	file      *os.File         // 日志文件
+	date      time.Time        // 日志文件的创建时间
+	levels    []logrus.Level   // 日志等级，为空时视为全部等级
 }
 
 func (d *DailyFileHook) Levels() []logrus.Level {
@@ -57,7 +58,13 @@ func (d *DailyFileHook) Fire(entry *logrus.Entry) error {
 		d.date = entry.Time.In(time.Local)
 	}
 	// 写入文件
-	b, err := entry.Bytes()
+	var b []byte
+	var err error
+	if d.Formatter != nil {
+		b, err = d.Formatter.Format(entry)
+	} else {
+		b, err = entry.Bytes()
+	}
 	if err != nil {
 		return err
 	}
@@ -73,3 +80,9 @@ var _ logrus.Hook = (*DailyFileHook)(nil)
 func NewDailyFileHook(layout string, levels ...logrus.Level) *DailyFileHook {
 	return &DailyFileHook{Layout: layout, levels: levels}
 }
+
+// NewDailyJSONFileHook 创建以 JSON 行格式写入文件的钩子，日志等级为空时视为全部等级
+func NewDailyJSONFileHook(layout string, levels ...logrus.Level) *DailyFileHook {
+	formatter, _ := NewFormatter("json")
+	return &DailyFileHook{Layout: layout, Formatter: formatter, levels: levels}
+}
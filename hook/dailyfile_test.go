@@ -0,0 +1,69 @@
+package hook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDailyJSONFileHook(t *testing.T) {
+	// 路径模板中的数字会被格式化，因此使用相对路径
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(NewDailyJSONFileHook("logs/2006-01-02.jsonl"))
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	logger.WithTime(day).WithField("title", "第一条").Info("多行\n消息")
+	logger.WithTime(day.Add(time.Hour)).WithField("count", 2).Warn("第二条")
+	// 跨天后写入新文件
+	logger.WithTime(day.AddDate(0, 0, 1)).Error("第三条")
+
+	lines := readJSONLines(t, filepath.Join(dir, "logs", "2024-01-01.jsonl"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for k, v := range map[string]any{"level": "info", "msg": "多行\n消息", "title": "第一条", "time": "2024-01-01 12:00:00"} {
+		if lines[0][k] != v {
+			t.Errorf("first line %s = %v, want %v", k, lines[0][k], v)
+		}
+	}
+	if lines[1]["level"] != "warning" || lines[1]["count"] != float64(2) {
+		t.Errorf("second line = %v", lines[1])
+	}
+	lines = readJSONLines(t, filepath.Join(dir, "logs", "2024-01-02.jsonl"))
+	if len(lines) != 1 || lines[0]["msg"] != "第三条" {
+		t.Errorf("next day lines = %v", lines)
+	}
+}
+
+// readJSONLines 读取每行一个 JSON 对象的文件
+func readJSONLines(t *testing.T, name string) (lines []map[string]any) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return
+}
@@ -3,12 +3,10 @@ package hook
 import (
 	"path/filepath"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/Drelf2018/dingtalk"
 	"github.com/sirupsen/logrus"
-	stripmd "github.com/writeas/go-strip-markdown"
 )
 
 // DingTalk 钉钉键
@@ -24,6 +22,7 @@ func (LoggerMsg) Type() dingtalk.MsgType {
 // DingTalkHook 钉钉机器人钩子
 type DingTalkHook struct {
 	*dingtalk.Bot
+	sent   inflight       // 正在发送的消息
 	levels []logrus.Level // 日志等级，为空时视为全部等级
 }
 
@@ -34,26 +33,42 @@ func (d *DingTalkHook) Levels() []logrus.Level {
 	return logrus.AllLevels
 }
 
+// Bound 判断日志事件是否绑定了当前机器人
+func (d *DingTalkHook) Bound(entry *logrus.Entry) bool {
+	data, ok := entry.Data[DingTalk].(string)
+	return ok && data == d.Bot.Name
+}
+
 // Fire 发送钉钉消息，发送失败时会将错误写入日志
 func (d *DingTalkHook) Fire(entry *logrus.Entry) error {
-	if data, ok := entry.Data[DingTalk]; ok {
-		if data, ok := data.(string); ok && data == d.Bot.Name {
-			log := &LoggerMsg{}
-			if _, err := d.Bot.Fill(entry, log); err != nil {
-				return err
-			}
-			go func(logger *logrus.Logger, msg *LoggerMsg) {
-				if err := d.Bot.Send(msg); err != nil {
-					logger.Error(err)
-				}
-			}(entry.Logger, log)
-		}
+	if !d.Bound(entry) {
+		return nil
+	}
+	log := &LoggerMsg{}
+	if _, err := d.Bot.Fill(entry, log); err != nil {
+		observeDrop(Name(d), "render")
+		return err
 	}
+	d.sent.Add()
+	go func(logger *logrus.Logger, msg *LoggerMsg) {
+		defer d.sent.Done()
+		start := time.Now()
+		err := d.Bot.Send(msg)
+		observeSend(Name(d), start, err)
+		if err != nil {
+			logger.Error(err)
+		}
+	}(entry.Logger, log)
 	return nil
 }
 
 var _ logrus.Hook = (*DingTalkHook)(nil)
 
+// Wait 等待正在发送的消息完成
+func (d *DingTalkHook) Wait() {
+	d.sent.Wait()
+}
+
 // Bind 将当前机器人绑定在日志上
 func (d *DingTalkHook) Bind(logger *logrus.Logger) *logrus.Entry {
 	return logger.WithField(DingTalk, d.Bot.Name)
@@ -99,7 +114,7 @@ func TimeFormat(t time.Time) string {
 
 // NewDingTalkHook 创建钉钉机器人钩子，日志等级为空时视为全部等级
 func NewDingTalkHook(bot *dingtalk.Bot, levels ...logrus.Level) *DingTalkHook {
-	bot.Funcs(template.FuncMap{"titlef": FirstLine, "prefix": Prefix, "stripmd": stripmd.Strip, "timef": TimeFormat}).Parse(LoggerMsg{
+	bot.Funcs(Funcs()).Parse(LoggerMsg{
 		Title:       " {{titlef .}}\n{{if stripmd .Message}}{{stripmd .Message}}\n{{end}}{{timef .Time}}",
 		Text:        "{{if .Data.banner}}{{.Data.banner}}\n{{end}}### {{titlef .}}\n\n{{prefix .Message \"#### \"}}\n\n###### {{timef .Time}}",
 		SingleTitle: "{{if .Data.button}}{{.Data.button}}{{end}}",
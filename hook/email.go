@@ -0,0 +1,374 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Email 邮件键
+const Email string = "email"
+
+// EmailSubject 邮件主题模板，模板数据为日志事件切片
+const EmailSubject string = `{{with index . 0}}{{titlef .}}{{end}}{{if gt (len .) 1}} 等 {{len .}} 条日志{{end}}`
+
+// EmailText 邮件纯文本模板，模板数据为日志事件切片
+const EmailText string = `{{range .}}{{titlef .}}
+{{.Message}}
+{{timef .Time}}
+
+{{end}}`
+
+// EmailHTML 邮件超文本模板，模板数据为日志事件切片
+const EmailHTML string = `<html><body>{{range .}}<div>{{if .Data.banner}}<p>{{.Data.banner}}</p>{{end}}<h3>{{titlef .}}</h3><pre>{{.Message}}</pre>{{if .Data.url}}<p><a href="{{.Data.url}}">{{if .Data.button}}{{.Data.button}}{{else}}{{.Data.url}}{{end}}</a></p>{{end}}<p><small>{{timef .Time}}</small></p></div><hr>{{end}}</body></html>`
+
+// DefaultEmailTimeout 邮件默认超时时间，避免无响应的服务器一直阻塞发送
+const DefaultEmailTimeout = 30 * time.Second
+
+// EmailBot 邮件机器人，使用 SMTP 发送邮件
+type EmailBot struct {
+	// 名称，可自定义
+	Name string `json:"name" yaml:"name" toml:"name" long:"name" ini-name:"name"`
+
+	// SMTP 服务器地址
+	Host string `json:"host" yaml:"host" toml:"host" long:"host" ini-name:"host"`
+
+	// SMTP 服务器端口，为零时根据加密方式选择 25 或 465 端口
+	Port int `json:"port" yaml:"port" toml:"port" long:"port" ini-name:"port"`
+
+	// 用户名，为空时不认证
+	Username string `json:"username" yaml:"username" toml:"username" long:"username" ini-name:"username"`
+
+	// 密码
+	Password string `json:"password" yaml:"password" toml:"password" long:"password" ini-name:"password"`
+
+	// 发件人
+	From string `json:"from" yaml:"from" toml:"from" long:"from" ini-name:"from"`
+
+	// 收件人
+	To []string `json:"to" yaml:"to" toml:"to" long:"to" ini-name:"to"`
+
+	// 加密方式，可选值为 none 、 starttls 和 tls ，为空时使用 starttls
+	Security string `json:"security" yaml:"security" toml:"security" long:"security" ini-name:"security" choice:"none" choice:"starttls" choice:"tls"`
+
+	// 合并发送的间隔，间隔内的日志会合并成一封邮件，非正数时立即发送
+	Interval time.Duration `json:"interval" yaml:"interval" toml:"interval" long:"interval" ini-name:"interval"`
+
+	// 全局请求超时时间，非正数时使用 DefaultEmailTimeout
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout" long:"timeout" ini-name:"timeout"`
+
+	// 主题模板
+	Subject *template.Template
+
+	// 纯文本模板
+	Text *template.Template
+
+	// 超文本模板，为空时只发送纯文本
+	HTML *htmltemplate.Template
+
+	// 自定义 TLS 配置，为空时使用服务器地址作为 ServerName
+	TLSConfig *tls.Config
+}
+
+// Parse 使用日志模板函数解析主题、纯文本和超文本模板，超文本模板为空时不创建
+func (e *EmailBot) Parse(subject, text, html string) (err error) {
+	e.Subject, err = template.New("subject").Funcs(Funcs()).Parse(subject)
+	if err != nil {
+		return
+	}
+	e.Text, err = template.New("text").Funcs(Funcs()).Parse(text)
+	if err != nil {
+		return
+	}
+	if html != "" {
+		e.HTML, err = htmltemplate.New("html").Funcs(htmltemplate.FuncMap(Funcs())).Parse(html)
+	}
+	return
+}
+
+// tlsConfig 获取 TLS 配置
+func (e *EmailBot) tlsConfig() *tls.Config {
+	if e.TLSConfig != nil {
+		return e.TLSConfig
+	}
+	return &tls.Config{ServerName: e.Host}
+}
+
+// Render 将数据渲染成完整的邮件
+func (e *EmailBot) Render(data any) ([]byte, error) {
+	if e.Subject == nil || e.Text == nil {
+		return nil, fmt.Errorf("email: template of %s cannot be nil", e.Name)
+	}
+	subject := &strings.Builder{}
+	err := e.Subject.Execute(subject, data)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "From: %s\r\n", e.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	// 依次写入纯文本和超文本，邮件客户端会优先显示最后一个能够显示的部分
+	parts := []struct {
+		contentType string
+		execute     func(*quotedprintable.Writer) error
+	}{
+		{"text/plain; charset=utf-8", func(w *quotedprintable.Writer) error { return e.Text.Execute(w, data) }},
+	}
+	if e.HTML != nil {
+		parts = append(parts, struct {
+			contentType string
+			execute     func(*quotedprintable.Writer) error
+		}{"text/html; charset=utf-8", func(w *quotedprintable.Writer) error { return e.HTML.Execute(w, data) }})
+	}
+	for _, part := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		err = part.execute(qw)
+		if err != nil {
+			return nil, err
+		}
+		err = qw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dial 连接 SMTP 服务器，根据加密方式建立 TLS 连接
+func (e *EmailBot) dial(ctx context.Context) (*smtp.Client, error) {
+	port := e.Port
+	if port == 0 {
+		if e.Security == "tls" {
+			port = 465
+		} else {
+			port = 25
+		}
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// 整个会话都不能超过上下文的截止时间
+	if deadline, ok := ctx.Deadline(); ok {
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if e.Security == "tls" {
+		conn = tls.Client(conn, e.tlsConfig())
+	}
+	c, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if e.Security == "" || e.Security == "starttls" {
+		err = c.StartTLS(e.tlsConfig())
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Post 携带上下文发送已渲染的邮件
+func (e *EmailBot) Post(ctx context.Context, msg []byte) error {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultEmailTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	c, err := e.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("email: failed to dial %s: %w", e.Name, err)
+	}
+	defer c.Close()
+	if e.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host))
+		if err != nil {
+			return fmt.Errorf("email: failed to auth %s: %w", e.Name, err)
+		}
+	}
+	err = c.Mail(e.From)
+	if err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		err = c.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// SendWithContext 携带上下文渲染并发送邮件
+func (e *EmailBot) SendWithContext(ctx context.Context, data any) error {
+	msg, err := e.Render(data)
+	if err != nil {
+		return err
+	}
+	return e.Post(ctx, msg)
+}
+
+// Send 渲染并发送邮件
+func (e *EmailBot) Send(data any) error {
+	return e.SendWithContext(context.Background(), data)
+}
+
+// EmailHook 邮件机器人钩子
+type EmailHook struct {
+	*EmailBot
+	mu      sync.Mutex
+	pending []*logrus.Entry // 等待合并发送的日志
+	timer   *time.Timer     // 合并发送计时器
+	sent    inflight        // 正在发送的邮件
+	levels  []logrus.Level  // 日志等级，为空时视为全部等级
+}
+
+func (e *EmailHook) Levels() []logrus.Level {
+	if len(e.levels) != 0 {
+		return e.levels
+	}
+	return logrus.AllLevels
+}
+
+// Bound 判断日志事件是否绑定了当前机器人
+func (e *EmailHook) Bound(entry *logrus.Entry) bool {
+	data, ok := entry.Data[Email].(string)
+	return ok && data == e.EmailBot.Name
+}
+
+// flush 发送所有等待中的日志
+func (e *EmailHook) flush(logger *logrus.Logger) {
+	e.mu.Lock()
+	entries := e.pending
+	e.pending = nil
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+	msg, err := e.EmailBot.Render(entries)
+	if err != nil {
+		observeDrop(Name(e), "render")
+		logger.Error(err)
+		return
+	}
+	e.sent.Add()
+	go func() {
+		defer e.sent.Done()
+		start := time.Now()
+		err := e.EmailBot.Post(context.Background(), msg)
+		observeSend(Name(e), start, err)
+		if err != nil {
+			logger.Error(err)
+		}
+	}()
+}
+
+// Fire 将日志加入等待队列，到达合并发送间隔后发送邮件，发送失败时会将错误写入日志
+func (e *EmailHook) Fire(entry *logrus.Entry) error {
+	if !e.Bound(entry) {
+		return nil
+	}
+	// 日志事件会被复用，需要复制一份
+	dup := entry.Dup()
+	dup.Level = entry.Level
+	dup.Message = entry.Message
+	dup.Caller = entry.Caller
+	e.mu.Lock()
+	e.pending = append(e.pending, dup)
+	if e.Interval <= 0 {
+		e.mu.Unlock()
+		e.flush(entry.Logger)
+		return nil
+	}
+	if e.timer == nil {
+		logger := entry.Logger
+		e.timer = time.AfterFunc(e.Interval, func() { e.flush(logger) })
+	}
+	e.mu.Unlock()
+	return nil
+}
+
+var _ logrus.Hook = (*EmailHook)(nil)
+
+// Wait 立即发送等待中的日志并等待正在发送的邮件完成
+func (e *EmailHook) Wait() {
+	e.mu.Lock()
+	var logger *logrus.Logger
+	if len(e.pending) != 0 {
+		logger = e.pending[0].Logger
+	}
+	e.mu.Unlock()
+	if logger != nil {
+		e.flush(logger)
+	}
+	e.sent.Wait()
+}
+
+// Bind 将当前机器人绑定在日志上
+func (e *EmailHook) Bind(logger *logrus.Logger) *logrus.Entry {
+	return logger.WithField(Email, e.EmailBot.Name)
+}
+
+// NewEmailHook 创建邮件机器人钩子，机器人未设置模板时使用默认模板，日志等级为空时视为全部等级
+func NewEmailHook(bot *EmailBot, levels ...logrus.Level) *EmailHook {
+	if bot.Subject == nil || bot.Text == nil {
+		_ = bot.Parse(EmailSubject, EmailText, EmailHTML)
+	}
+	return &EmailHook{EmailBot: bot, levels: levels}
+}
@@ -0,0 +1,303 @@
+package hook
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// smtpMessage SMTP 测试服务器收到的邮件
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+	TLS  bool // 是否通过 STARTTLS 发送
+	Auth bool // 是否认证
+}
+
+// smtpStub 只支持测试所需命令的 SMTP 服务器
+type smtpStub struct {
+	ln       net.Listener
+	tls      *tls.Config // 不为空时支持 STARTTLS
+	messages chan smtpMessage
+}
+
+func newSMTPStub(t *testing.T, config *tls.Config) *smtpStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{ln: ln, tls: config, messages: make(chan smtpMessage, 16)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// bot 创建连接到测试服务器的邮件机器人
+func (s *smtpStub) bot() *EmailBot {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	bot := &EmailBot{Name: "test", Host: host, Port: p, From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}, Timeout: 5 * time.Second}
+	if s.tls == nil {
+		bot.Security = "none"
+	}
+	return bot
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	var msg smtpMessage
+	tp.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.tls != nil && !msg.TLS {
+				tp.PrintfLine("250-stub\r\n250 STARTTLS")
+			} else {
+				tp.PrintfLine("250-stub\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, tp, msg.TLS = tlsConn, textproto.NewConn(tlsConn), true
+		case "AUTH":
+			msg.Auth = true
+			tp.PrintfLine("235 ok")
+		case "MAIL":
+			msg.From = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.messages <- msg
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+// receive 等待测试服务器收到邮件
+func (s *smtpStub) receive(t *testing.T) smtpMessage {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for email")
+		return smtpMessage{}
+	}
+}
+
+// testTLSConfig 使用 httptest 的证书创建服务端和客户端 TLS 配置
+func testTLSConfig(t *testing.T) (server, client *tls.Config) {
+	srv := httptest.NewTLSServer(nil)
+	t.Cleanup(srv.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return &tls.Config{Certificates: srv.TLS.Certificates}, &tls.Config{RootCAs: pool, ServerName: "example.com"}
+}
+
+func emailEntry(title, msg string) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.ErrorLevel
+	entry.Time = time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	entry.Message = msg
+	entry.Data = logrus.Fields{"title": title, Email: "test"}
+	return entry
+}
+
+// readParts 解析邮件，返回主题和各部分的内容类型与解码后的正文
+func readParts(t *testing.T, data string) (string, map[string]string) {
+	t.Helper()
+	m, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %s: %v", m.Header.Get("Content-Type"), err)
+	}
+	parts := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// multipart.Reader 会自动解码 quoted-printable
+		b, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[p.Header.Get("Content-Type")] = string(b)
+	}
+	return subject, parts
+}
+
+func TestEmailPlain(t *testing.T) {
+	stub := newSMTPStub(t, nil)
+	bot := stub.bot()
+	if err := bot.Parse(EmailSubject, EmailText, EmailHTML); err != nil {
+		t.Fatal(err)
+	}
+	err := bot.Send([]*logrus.Entry{emailEntry("发送失败", "a <b> & c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := stub.receive(t)
+	if msg.TLS || msg.Auth || msg.From != "bot@example.com" || strings.Join(msg.To, ",") != "a@example.com,b@example.com" {
+		t.Errorf("unexpected envelope: %+v", msg)
+	}
+	subject, parts := readParts(t, msg.Data)
+	if subject != "[ERROR] 发送失败" {
+		t.Errorf("subject = %q", subject)
+	}
+	if text := parts["text/plain; charset=utf-8"]; text != "[ERROR] 发送失败\na <b> & c\n2024-01-02 03:04:05\n\n" {
+		t.Errorf("text = %q", text)
+	}
+	if html := parts["text/html; charset=utf-8"]; !strings.Contains(html, "<pre>a &lt;b&gt; &amp; c</pre>") {
+		t.Errorf("html = %q", html)
+	}
+}
+
+func TestEmailStartTLS(t *testing.T) {
+	server, client := testTLSConfig(t)
+	stub := newSMTPStub(t, server)
+	bot := stub.bot()
+	bot.TLSConfig = client
+	bot.Username, bot.Password = "user", "pass"
+	if err := bot.Parse(EmailSubject, EmailText, ""); err != nil {
+		t.Fatal(err)
+	}
+	err := bot.Send([]*logrus.Entry{emailEntry("发送失败", "hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := stub.receive(t)
+	if !msg.TLS || !msg.Auth {
+		t.Errorf("expected STARTTLS and auth: %+v", msg)
+	}
+	_, parts := readParts(t, msg.Data)
+	if len(parts) != 1 || parts["text/plain; charset=utf-8"] == "" {
+		t.Errorf("unexpected parts: %v", parts)
+	}
+}
+
+func TestEmailHookBatching(t *testing.T) {
+	stub := newSMTPStub(t, nil)
+	bot := stub.bot()
+	bot.Interval = 100 * time.Millisecond
+	hook := NewEmailHook(bot)
+	logger := NewWithConsole(nil, hook)
+	entry := hook.Bind(logger)
+	logger.Error("unbound")
+	for i := 0; i < 3; i++ {
+		entry.WithField("title", "出错").Errorf("第 %d 次", i)
+	}
+	msg := stub.receive(t)
+	subject, parts := readParts(t, msg.Data)
+	if subject != "[ERROR] 出错 等 3 条日志" {
+		t.Errorf("subject = %q", subject)
+	}
+	text := parts["text/plain; charset=utf-8"]
+	for i := 0; i < 3; i++ {
+		if !strings.Contains(text, "第 "+strconv.Itoa(i)+" 次") {
+			t.Errorf("text missing entry %d: %q", i, text)
+		}
+	}
+	if strings.Contains(text, "unbound") {
+		t.Errorf("unbound entry sent: %q", text)
+	}
+
+	// Wait 会立即发送等待中的日志
+	entry.Error("flush")
+	hook.Wait()
+	select {
+	case msg := <-stub.messages:
+		if _, parts := readParts(t, msg.Data); !strings.Contains(parts["text/plain; charset=utf-8"], "flush") {
+			t.Errorf("unexpected flushed email: %v", parts)
+		}
+	default:
+		t.Error("Wait did not flush pending entries")
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	// 接受连接后不再响应的服务器
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, bufio.NewReader(conn))
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	bot := &EmailBot{Name: "hang", Host: host, Port: p, Security: "none", Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	err = bot.Post(context.Background(), []byte("x"))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("post blocked for %s", elapsed)
+	}
+}
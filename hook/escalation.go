@@ -0,0 +1,138 @@
+package hook
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var numberPattern = regexp.MustCompile(`\d+`)
+
+// Escalate 去重升级键，值为真的日志事件才会参与去重升级，其余日志事件直接交给通知钩子
+const Escalate string = "escalate"
+
+// Escalated 判断日志事件是否需要去重升级
+func Escalated(entry *logrus.Entry) bool {
+	escalate, _ := entry.Data[Escalate].(bool)
+	return escalate
+}
+
+// Fingerprint 生成日志事件指纹，由日志等级、标题和将数字替换为占位符后的消息模板组成
+func Fingerprint(entry *logrus.Entry) string {
+	b := &strings.Builder{}
+	b.WriteString(entry.Level.String())
+	b.WriteByte('|')
+	if value, ok := entry.Data["title"]; ok {
+		if title, ok := value.(string); ok {
+			b.WriteString(title)
+		}
+	}
+	b.WriteByte('|')
+	b.WriteString(numberPattern.ReplaceAllString(entry.Message, "#"))
+	return b.String()
+}
+
+// occurrence 同一指纹日志事件的出现记录
+type occurrence struct {
+	times     []time.Time // 窗口内的出现时间
+	escalated time.Time   // 上次升级通知的时间
+}
+
+// EscalationHook 去重升级钩子，同一指纹的日志事件在窗口内出现次数超过阈值时才会交给通知钩子处理，
+// 升级后的一个窗口内不再重复通知。只有带有 Escalate 字段的日志事件参与统计
+type EscalationHook struct {
+	Hook      logrus.Hook   // 通知钩子
+	Window    time.Duration // 统计窗口
+	Threshold int           // 窗口内出现次数阈值，出现次数超过阈值时通知，非正数时第一次出现就通知
+	mu        sync.Mutex
+	records   map[string]*occurrence
+	swept     time.Time // 上次清理过期记录的时间
+}
+
+func (e *EscalationHook) Levels() []logrus.Level {
+	return e.Hook.Levels()
+}
+
+// allow 记录一次出现并判断是否需要升级通知
+func (e *EscalationHook) allow(fingerprint string, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.records == nil {
+		e.records = make(map[string]*occurrence)
+	}
+	// 定期清理整个窗口内都没有再出现的记录
+	if now.Sub(e.swept) > e.Window {
+		for key, o := range e.records {
+			if len(o.times) == 0 || now.Sub(o.times[len(o.times)-1]) > e.Window {
+				delete(e.records, key)
+			}
+		}
+		e.swept = now
+	}
+	o, ok := e.records[fingerprint]
+	if !ok {
+		o = &occurrence{}
+		e.records[fingerprint] = o
+	}
+	// 移除窗口外的出现时间
+	i := 0
+	for i < len(o.times) && now.Sub(o.times[i]) > e.Window {
+		i++
+	}
+	o.times = append(o.times[i:], now)
+	// 升级后的一个窗口内不再重复通知
+	if !o.escalated.IsZero() && now.Sub(o.escalated) <= e.Window {
+		return false
+	}
+	if len(o.times) <= e.Threshold {
+		return false
+	}
+	o.escalated = now
+	return true
+}
+
+// Binder 可以判断日志事件是否绑定了自身的钩子
+type Binder interface {
+	Bound(entry *logrus.Entry) bool
+}
+
+// Bound 判断日志事件是否绑定了通知钩子，通知钩子不能判断时视为已绑定
+func (e *EscalationHook) Bound(entry *logrus.Entry) bool {
+	if binder, ok := e.Hook.(Binder); ok {
+		return binder.Bound(entry)
+	}
+	return true
+}
+
+// Fire 统计日志事件，超过阈值时交给通知钩子处理，未绑定通知钩子的日志事件不参与统计，
+// 没有 Escalate 字段的日志事件直接交给通知钩子
+func (e *EscalationHook) Fire(entry *logrus.Entry) error {
+	if !Escalated(entry) {
+		return e.Hook.Fire(entry)
+	}
+	if !e.Bound(entry) {
+		return nil
+	}
+	if !e.allow(Fingerprint(entry), entry.Time) {
+		observeDrop(Name(e), "suppressed")
+		return nil
+	}
+	return e.Hook.Fire(entry)
+}
+
+var _ logrus.Hook = (*EscalationHook)(nil)
+
+// Wait 等待通知钩子发送完成
+func (e *EscalationHook) Wait() {
+	if waiter, ok := e.Hook.(Waiter); ok {
+		waiter.Wait()
+	}
+}
+
+// NewEscalationHook 创建去重升级钩子，同一指纹的日志事件在窗口内出现超过 threshold 次才会通知
+func NewEscalationHook(hook logrus.Hook, window time.Duration, threshold int) *EscalationHook {
+	return &EscalationHook{Hook: hook, Window: window, Threshold: threshold}
+}
@@ -0,0 +1,103 @@
+package hook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFingerprint(t *testing.T) {
+	newEntry := func(level logrus.Level, title, msg string) *logrus.Entry {
+		return &logrus.Entry{Level: level, Message: msg, Data: logrus.Fields{"title": title}}
+	}
+	a := Fingerprint(newEntry(logrus.ErrorLevel, "迭代微博出错", "status 502 after 3 retries"))
+	b := Fingerprint(newEntry(logrus.ErrorLevel, "迭代微博出错", "status 504 after 12 retries"))
+	if a != b {
+		t.Errorf("fingerprints differ: %q != %q", a, b)
+	}
+	if want := "error|迭代微博出错|status # after # retries"; a != want {
+		t.Errorf("fingerprint = %q, want %q", a, want)
+	}
+	if c := Fingerprint(newEntry(logrus.WarnLevel, "迭代微博出错", "status 502 after 3 retries")); c == a {
+		t.Error("level is not part of fingerprint")
+	}
+	if c := Fingerprint(newEntry(logrus.ErrorLevel, "发送微博失败", "status 502 after 3 retries")); c == a {
+		t.Error("title is not part of fingerprint")
+	}
+}
+
+func TestEscalationAllow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	e := NewEscalationHook(nil, 10*time.Minute, 3)
+	steps := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, false},
+		{time.Minute, false},
+		{2 * time.Minute, false},                 // 第三次刚好达到阈值，不通知
+		{3 * time.Minute, true},                  // 第四次超过阈值
+		{4 * time.Minute, false},                 // 升级后的一个窗口内不再通知
+		{13*time.Minute + 30*time.Second, false}, // 窗口内只剩两次
+		{13*time.Minute + 45*time.Second, false}, // 窗口内刚好三次
+		{14 * time.Minute, true},                 // 窗口内再次超过三次
+	}
+	for _, step := range steps {
+		if got := e.allow("fp", at(step.at)); got != step.want {
+			t.Errorf("allow at %s = %v, want %v", step.at, got, step.want)
+		}
+	}
+
+	// 超出窗口的出现不计入阈值
+	e = NewEscalationHook(nil, 10*time.Minute, 1)
+	if e.allow("fp", at(0)) || e.allow("fp", at(11*time.Minute)) {
+		t.Error("occurrences outside window should not escalate")
+	}
+	if !e.allow("fp", at(12*time.Minute)) {
+		t.Error("two occurrences within window should escalate")
+	}
+	// 不同指纹分别统计
+	if e.allow("other", at(12*time.Minute)) {
+		t.Error("fingerprints should be counted separately")
+	}
+
+	// 阈值为 0 或负数时第一次出现就通知
+	for _, threshold := range []int{0, -1} {
+		e = NewEscalationHook(nil, time.Minute, threshold)
+		if !e.allow("fp", at(0)) || e.allow("fp", at(30*time.Second)) || !e.allow("fp", at(2*time.Minute)) {
+			t.Errorf("threshold %d should escalate once per window", threshold)
+		}
+	}
+}
+
+type countHook struct{ fired int }
+
+func (*countHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (c *countHook) Fire(*logrus.Entry) error {
+	c.fired++
+	return nil
+}
+
+func TestEscalationFire(t *testing.T) {
+	inner := &countHook{}
+	e := NewEscalationHook(inner, 10*time.Minute, 1)
+	now := time.Now()
+	// 没有 Escalate 字段的日志事件每次都会通知
+	for i := 0; i < 3; i++ {
+		e.Fire(&logrus.Entry{Time: now, Level: logrus.ErrorLevel, Message: "send failed", Data: logrus.Fields{"title": "发送微博失败"}})
+	}
+	if inner.fired != 3 {
+		t.Errorf("fired %d times, want 3", inner.fired)
+	}
+	// 带有 Escalate 字段的日志事件在窗口内只通知一次
+	for i := 0; i < 3; i++ {
+		e.Fire(&logrus.Entry{Time: now.Add(time.Duration(i) * time.Second), Level: logrus.ErrorLevel, Message: "status 502", Data: logrus.Fields{"title": "迭代微博出错", Escalate: true}})
+	}
+	if inner.fired != 4 {
+		t.Errorf("fired %d times, want 4", inner.fired)
+	}
+}
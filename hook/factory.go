@@ -8,22 +8,43 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// NestedFormatter 默认的嵌套格式化器
+func NestedFormatter() logrus.Formatter {
+	return &nested.Formatter{
+		TimestampFormat:       "2006-01-02 15:04:05",
+		NoColors:              true,
+		ShowFullLevel:         true,
+		CustomCallerFormatter: func(*runtime.Frame) string { return "" },
+	}
+}
+
+// ColoredNestedFormatter 带颜色的嵌套格式化器，用于终端输出
+func ColoredNestedFormatter() logrus.Formatter {
+	return &nested.Formatter{
+		TimestampFormat:       "2006-01-02 15:04:05",
+		ShowFullLevel:         true,
+		CustomCallerFormatter: func(*runtime.Frame) string { return "" },
+	}
+}
+
 func New(level logrus.Level, hooks ...logrus.Hook) *logrus.Logger {
+	return NewWithConsole(ConsoleHook(logrus.AllLevels[:level+1]), hooks...)
+}
+
+// NewWithConsole 使用指定的控制台钩子创建日志，控制台钩子为空时不输出到控制台
+func NewWithConsole(console logrus.Hook, hooks ...logrus.Hook) *logrus.Logger {
 	logger := &logrus.Logger{
-		Out:   io.Discard,
-		Hooks: make(logrus.LevelHooks),
-		Formatter: &nested.Formatter{
-			TimestampFormat:       "2006-01-02 15:04:05",
-			NoColors:              true,
-			ShowFullLevel:         true,
-			CustomCallerFormatter: func(*runtime.Frame) string { return "" },
-		},
+		Out:          io.Discard,
+		Hooks:        make(logrus.LevelHooks),
+		Formatter:    NestedFormatter(),
 		ReportCaller: true,
 		Level:        logrus.TraceLevel,
 	}
-	logger.AddHook(ConsoleHook(logrus.AllLevels[:level+1]))
+	if console != nil {
+		logger.AddHook(NewMetricsHook(console))
+	}
 	for _, hook := range hooks {
-		logger.AddHook(hook)
+		logger.AddHook(NewMetricsHook(hook))
 	}
 	return logger
 }
@@ -1,15 +1,25 @@
 module github.com/Drelf2018/exp/hook
 
-go 1.18
+go 1.21
 
 require (
 	github.com/Drelf2018/dingtalk v0.0.0-20260119185921-eb58aad0f621
 	github.com/antonfisher/nested-logrus-formatter v1.3.1
+	github.com/jessevdk/go-flags v1.6.1
+	github.com/prometheus/client_golang v1.20.5
 	github.com/sirupsen/logrus v1.9.3
 	github.com/writeas/go-strip-markdown v2.0.1+incompatible
 )
 
 require (
 	github.com/Drelf2018/req v0.0.0-20260119155603-2094703bdf97 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 )
@@ -0,0 +1,145 @@
+package hook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Drelf2018/dingtalk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// 钩子处理的日志事件数量
+	entriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hook",
+		Name:      "entries_total",
+		Help:      "Number of log entries fired per hook and level.",
+	}, []string{"hook", "level"})
+
+	// 通知发送耗时
+	sendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "hook",
+		Name:      "send_duration_seconds",
+		Help:      "Latency of notification sends per hook.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"hook"})
+
+	// 通知发送失败数量
+	sendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hook",
+		Name:      "send_failures_total",
+		Help:      "Number of failed notification sends per hook and error code.",
+	}, []string{"hook", "code"})
+
+	// 未发送的通知数量
+	droppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hook",
+		Name:      "dropped_total",
+		Help:      "Number of notifications dropped per hook and reason.",
+	}, []string{"hook", "reason"})
+)
+
+// MetricsHandler 以 Prometheus 格式输出指标的 http.Handler
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Name 获取钩子在指标中的名称
+func Name(hook logrus.Hook) string {
+	switch h := hook.(type) {
+	case *MetricsHook:
+		return Name(h.Hook)
+	case *EscalationHook:
+		return Name(h.Hook)
+	case ConsoleHook, *WriterHook:
+		return "console"
+	case *DailyFileHook:
+		return "file"
+	case *DingTalkHook:
+		return DingTalk + ":" + h.Bot.Name
+	case *WebhookHook:
+		return Webhook + ":" + h.WebhookBot.Name
+	case *EmailHook:
+		return Email + ":" + h.EmailBot.Name
+	default:
+		return "unknown"
+	}
+}
+
+// ErrorCode 获取发送错误在指标中的错误码
+func ErrorCode(err error) string {
+	var sendErr dingtalk.SendError
+	if errors.As(err, &sendErr) {
+		return strconv.Itoa(sendErr.ErrCode)
+	}
+	var webhookErr WebhookError
+	if errors.As(err, &webhookErr) {
+		return strconv.Itoa(webhookErr.ErrCode)
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return strconv.Itoa(smtpErr.Code)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	}
+	return "unknown"
+}
+
+// observeSend 记录一次通知发送的耗时和结果
+func observeSend(name string, start time.Time, err error) {
+	sendDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		sendFailures.WithLabelValues(name, ErrorCode(err)).Inc()
+	}
+}
+
+// observeDrop 记录一次未发送的通知
+func observeDrop(name, reason string) {
+	droppedTotal.WithLabelValues(name, reason).Inc()
+}
+
+// MetricsHook 统计日志事件数量的钩子，通知钩子只统计绑定了自身的日志事件
+type MetricsHook struct {
+	logrus.Hook
+}
+
+func (m *MetricsHook) Fire(entry *logrus.Entry) error {
+	if binder, ok := m.Hook.(Binder); !ok || binder.Bound(entry) {
+		entriesTotal.WithLabelValues(Name(m.Hook), entry.Level.String()).Inc()
+	}
+	return m.Hook.Fire(entry)
+}
+
+var _ logrus.Hook = (*MetricsHook)(nil)
+
+// Wait 等待被包装的钩子发送完成
+func (m *MetricsHook) Wait() {
+	if waiter, ok := m.Hook.(Waiter); ok {
+		waiter.Wait()
+	}
+}
+
+// NewMetricsHook 创建统计日志事件数量的钩子
+func NewMetricsHook(hook logrus.Hook) *MetricsHook {
+	return &MetricsHook{Hook: hook}
+}
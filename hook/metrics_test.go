@@ -0,0 +1,56 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// counterValue 获取计数器指标的当前值
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// boundHook 只处理带有 bound 字段的日志事件的钩子
+type boundHook struct{ countHook }
+
+func (*boundHook) Bound(entry *logrus.Entry) bool {
+	_, ok := entry.Data["bound"]
+	return ok
+}
+
+func TestMetricsHookCountsBoundEntries(t *testing.T) {
+	inner := &boundHook{}
+	logger := NewWithConsole(nil, NewEscalationHook(inner, 0, 1))
+	labels := map[string]string{"hook": "unknown", "level": "error"}
+	before := counterValue(t, "hook_entries_total", labels)
+
+	logger.Error("unbound")
+	if got := counterValue(t, "hook_entries_total", labels) - before; got != 0 {
+		t.Errorf("unbound entries counted %v times", got)
+	}
+	logger.WithField("bound", true).Error("bound")
+	if got := counterValue(t, "hook_entries_total", labels) - before; got != 1 {
+		t.Errorf("bound entries counted %v times, want 1", got)
+	}
+}
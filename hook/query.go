@@ -0,0 +1,218 @@
+package hook
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Record 从日志文件中解析出的日志记录
+type Record struct {
+	Time    time.Time         `json:"time"`    // 日志时间
+	Level   logrus.Level      `json:"level"`   // 日志等级
+	Fields  map[string]string `json:"fields"`  // 日志字段
+	Message string            `json:"message"` // 日志消息
+	Raw     string            `json:"-"`       // 原始文本
+}
+
+func (r Record) String() string {
+	return r.Raw
+}
+
+// recordPattern 嵌套格式化器输出的日志首行，形如 "2006-01-02 15:04:05 [INFO] [title:xx] message"
+var recordPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) \[([A-Z]+)\] (.*)$`)
+
+// ParseNestedLine 解析嵌套格式化器输出的日志首行，字段值中包含 "] " 时可能会解析错误
+func ParseNestedLine(line string) (r Record, ok bool) {
+	match := recordPattern.FindStringSubmatch(line)
+	if match == nil {
+		return r, false
+	}
+	var err error
+	r.Time, err = time.ParseInLocation(time.DateTime, match[1], time.Local)
+	if err != nil {
+		return r, false
+	}
+	r.Level, err = logrus.ParseLevel(match[2])
+	if err != nil {
+		return r, false
+	}
+	r.Fields = make(map[string]string)
+	rest := match[3]
+	for strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "] ")
+		if end == -1 {
+			break
+		}
+		key, value, found := strings.Cut(rest[1:end], ":")
+		if !found {
+			break
+		}
+		r.Fields[key] = value
+		rest = rest[end+2:]
+	}
+	r.Message = rest
+	r.Raw = line
+	return r, true
+}
+
+// ParseJSONLine 解析 JSON 格式化器输出的日志行，缺少时间或等级键的 JSON 对象不视为日志行
+func ParseJSONLine(line string) (r Record, ok bool) {
+	m := make(map[string]any)
+	if json.Unmarshal([]byte(line), &m) != nil {
+		return r, false
+	}
+	if _, ok := m[logrus.FieldKeyTime]; !ok {
+		return r, false
+	}
+	if _, ok := m[logrus.FieldKeyLevel]; !ok {
+		return r, false
+	}
+	r.Fields = make(map[string]string, len(m))
+	for k, v := range m {
+		switch k {
+		case logrus.FieldKeyTime:
+			s, _ := v.(string)
+			t, err := time.ParseInLocation(time.DateTime, s, time.Local)
+			if err != nil {
+				t, err = time.Parse(time.RFC3339, s)
+				if err != nil {
+					return r, false
+				}
+			}
+			r.Time = t
+		case logrus.FieldKeyLevel:
+			s, _ := v.(string)
+			level, err := logrus.ParseLevel(s)
+			if err != nil {
+				return r, false
+			}
+			r.Level = level
+		case logrus.FieldKeyMsg:
+			r.Message, _ = v.(string)
+		default:
+			r.Fields[k] = fmt.Sprint(v)
+		}
+	}
+	r.Raw = line
+	return r, true
+}
+
+// ReadRecords 读取日志记录，不能被解析的行视为上一条记录的延续
+func ReadRecords(r io.Reader, yield func(Record) bool) error {
+	var last *Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		record, ok := ParseJSONLine(line)
+		if !ok {
+			record, ok = ParseNestedLine(line)
+		}
+		if ok {
+			if last != nil && !yield(*last) {
+				return nil
+			}
+			last = &record
+		} else if last != nil {
+			last.Message += "\n" + line
+			last.Raw += "\n" + line
+		}
+	}
+	if last != nil {
+		yield(*last)
+	}
+	return scanner.Err()
+}
+
+// Query 日志查询条件
+type Query struct {
+	Since   time.Time      // 起始时间，为零值时不限制
+	Until   time.Time      // 截止时间，为零值时不限制
+	Levels  []logrus.Level // 日志等级，为空时视为全部等级
+	Title   string         // 标题字段，为空时不限制
+	Pattern *regexp.Regexp // 匹配原始文本的正则表达式，为空时不限制
+}
+
+// Match 判断日志记录是否满足查询条件
+func (q *Query) Match(r Record) bool {
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Time.After(q.Until) {
+		return false
+	}
+	if len(q.Levels) != 0 {
+		var found bool
+		for _, level := range q.Levels {
+			if level == r.Level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Title != "" && r.Fields["title"] != q.Title {
+		return false
+	}
+	if q.Pattern != nil && !q.Pattern.MatchString(r.Raw) {
+		return false
+	}
+	return true
+}
+
+// Files 根据日志文件路径模板选择日期范围内存在的日志文件，按日期升序排列
+func (q *Query) Files(layout string) []string {
+	since, until := q.Since, q.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+	if since.IsZero() {
+		since = until.AddDate(0, 0, -30)
+	}
+	var files []string
+	day := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.Local)
+	for ; !day.After(until); day = day.AddDate(0, 0, 1) {
+		path := day.Format(layout)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, filepath.Clean(path))
+		}
+	}
+	return files
+}
+
+// Run 按时间顺序查询日志文件中满足条件的日志记录
+func (q *Query) Run(layout string) ([]Record, error) {
+	var records []Record
+	for _, path := range q.Files(layout) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = ReadRecords(f, func(r Record) bool {
+			if q.Match(r) {
+				records = append(records, r)
+			}
+			return true
+		})
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("hook: failed to read %s: %w", path, err)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
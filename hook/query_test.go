@@ -0,0 +1,80 @@
+package hook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseJSONLine(t *testing.T) {
+	r, ok := ParseJSONLine(`{"level":"error","msg":"boom","time":"2024-01-02 03:04:05","title":"出错"}`)
+	if !ok || r.Level != logrus.ErrorLevel || r.Message != "boom" || r.Fields["title"] != "出错" || r.Time.Hour() != 3 {
+		t.Errorf("unexpected record: %+v %v", r, ok)
+	}
+	for _, line := range []string{
+		`{"code":1}`,
+		`{"level":"error","msg":"no time"}`,
+		`{"time":"2024-01-02 03:04:05","msg":"no level"}`,
+		`{"level":"nope","time":"2024-01-02 03:04:05"}`,
+		`[1,2]`,
+	} {
+		if _, ok := ParseJSONLine(line); ok {
+			t.Errorf("%s should not be a record", line)
+		}
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	input := strings.Join([]string{
+		`continuation before first record`,
+		`2024-01-02 03:04:05 [INFO] [target:1] 保存微博: hello`,
+		`{"level":"warning","msg":"json record","time":"2024-01-02 03:04:06"}`,
+		`2024-01-02 03:04:07 [ERROR] [title:迭代微博出错] request failed:`,
+		`{"code":1}`,
+		`{"ok":-100,"msg":"login"}`,
+		`  at line 3`,
+		`{"level":"info","msg":"after","time":"2024-01-02T03:04:08+08:00"}`,
+	}, "\n")
+	var records []Record
+	err := ReadRecords(strings.NewReader(input), func(r Record) bool {
+		records = append(records, r)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d records: %+v", len(records), records)
+	}
+	want := []struct {
+		level   logrus.Level
+		message string
+	}{
+		{logrus.InfoLevel, "保存微博: hello"},
+		{logrus.WarnLevel, "json record"},
+		{logrus.ErrorLevel, "request failed:\n{\"code\":1}\n{\"ok\":-100,\"msg\":\"login\"}\n  at line 3"},
+		{logrus.InfoLevel, "after"},
+	}
+	for i, w := range want {
+		if records[i].Level != w.level || records[i].Message != w.message {
+			t.Errorf("record %d = %s %q, want %s %q", i, records[i].Level, records[i].Message, w.level, w.message)
+		}
+		if records[i].Time.IsZero() {
+			t.Errorf("record %d has zero time", i)
+		}
+	}
+	if records[2].Fields["title"] != "迭代微博出错" {
+		t.Errorf("unexpected fields: %v", records[2].Fields)
+	}
+
+	// 提前停止时不再返回后续记录
+	var count int
+	ReadRecords(strings.NewReader(input), func(Record) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("yield called %d times after stop", count)
+	}
+}
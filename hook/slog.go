@@ -0,0 +1,105 @@
+package hook
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogrusLevel 将 slog 日志等级转换为 logrus 日志等级
+func LogrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
+
+// SlogHandler 将 slog 日志记录交给 logrus 日志的钩子处理的 slog.Handler
+type SlogHandler struct {
+	logger *logrus.Logger
+	fields logrus.Fields // 预设字段
+	prefix string        // 分组前缀
+}
+
+func (s *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return s.logger.IsLevelEnabled(LogrusLevel(level))
+}
+
+// addAttr 将属性写入字段，分组属性会展开为以 "." 连接的键
+func addAttr(fields logrus.Fields, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			addAttr(fields, prefix, a)
+		}
+		return
+	}
+	fields[prefix+attr.Key] = attr.Value.Any()
+}
+
+// Handle 将日志记录转换为日志事件后触发对应等级的钩子
+func (s *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make(logrus.Fields, len(s.fields)+record.NumAttrs())
+	for k, v := range s.fields {
+		fields[k] = v
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(fields, s.prefix, attr)
+		return true
+	})
+	entry := logrus.NewEntry(s.logger).WithContext(ctx).WithFields(fields)
+	entry.Time = record.Time
+	entry.Level = LogrusLevel(record.Level)
+	entry.Message = record.Message
+	if s.logger.ReportCaller && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		entry.Caller = &frame
+	}
+	return s.logger.Hooks.Fire(entry.Level, entry)
+}
+
+func (s *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(logrus.Fields, len(s.fields)+len(attrs))
+	for k, v := range s.fields {
+		fields[k] = v
+	}
+	for _, attr := range attrs {
+		addAttr(fields, s.prefix, attr)
+	}
+	return &SlogHandler{logger: s.logger, fields: fields, prefix: s.prefix}
+}
+
+func (s *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	return &SlogHandler{logger: s.logger, fields: s.fields, prefix: s.prefix + name + "."}
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler 创建将日志记录交给 logrus 日志的钩子处理的 slog.Handler
+func NewSlogHandler(logger *logrus.Logger) *SlogHandler {
+	return &SlogHandler{logger: logger}
+}
+
+// NewSlog 创建将日志记录交给 logrus 日志的钩子处理的 slog.Logger
+func NewSlog(logger *logrus.Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(logger))
+}
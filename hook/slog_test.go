@@ -0,0 +1,80 @@
+package hook
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// entriesHook 记录触发的日志事件
+type entriesHook struct{ entries []*logrus.Entry }
+
+func (*entriesHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (e *entriesHook) Fire(entry *logrus.Entry) error {
+	e.entries = append(e.entries, entry)
+	return nil
+}
+
+func TestSlogHandle(t *testing.T) {
+	logger := logrus.New()
+	hook := &entriesHook{}
+	logger.AddHook(hook)
+	log := NewSlog(logger).With("a", 1).WithGroup("g")
+	log.Warn("消息", "b", 2, slog.Group("h", "c", 3), slog.Group("", "d", 4), slog.Group("empty"))
+	if len(hook.entries) != 1 {
+		t.Fatalf("fired %d entries, want 1", len(hook.entries))
+	}
+	entry := hook.entries[0]
+	if entry.Level != logrus.WarnLevel || entry.Message != "消息" {
+		t.Errorf("level = %s, message = %s", entry.Level, entry.Message)
+	}
+	// 分组属性展开为以 "." 连接的键，空键的分组直接展开，空分组忽略
+	want := logrus.Fields{"a": int64(1), "g.b": int64(2), "g.h.c": int64(3), "g.d": int64(4)}
+	if !reflect.DeepEqual(entry.Data, want) {
+		t.Errorf("fields = %v, want %v", entry.Data, want)
+	}
+	// 派生的处理器不影响原处理器的预设字段
+	NewSlog(logger).With("a", 1).Info("另一条", "b", 2)
+	if want := (logrus.Fields{"a": int64(1), "b": int64(2)}); !reflect.DeepEqual(hook.entries[1].Data, want) {
+		t.Errorf("fields = %v, want %v", hook.entries[1].Data, want)
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	for _, c := range []struct {
+		level slog.Level
+		want  logrus.Level
+	}{
+		{slog.LevelError + 4, logrus.ErrorLevel},
+		{slog.LevelError, logrus.ErrorLevel},
+		{slog.LevelWarn, logrus.WarnLevel},
+		{slog.LevelInfo + 2, logrus.InfoLevel},
+		{slog.LevelInfo, logrus.InfoLevel},
+		{slog.LevelDebug, logrus.DebugLevel},
+		{slog.LevelDebug - 4, logrus.TraceLevel},
+	} {
+		if got := LogrusLevel(c.level); got != c.want {
+			t.Errorf("LogrusLevel(%s) = %s, want %s", c.level, got, c.want)
+		}
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	hook := &entriesHook{}
+	logger.AddHook(hook)
+	h := NewSlogHandler(logger)
+	if h.Enabled(context.Background(), slog.LevelDebug) || !h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("enabled levels do not follow logger level")
+	}
+	log := slog.New(h)
+	log.Debug("忽略")
+	log.Error("错误")
+	if len(hook.entries) != 1 || hook.entries[0].Level != logrus.ErrorLevel {
+		t.Errorf("entries = %v", hook.entries)
+	}
+}
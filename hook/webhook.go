@@ -0,0 +1,288 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	stripmd "github.com/writeas/go-strip-markdown"
+)
+
+// Webhook 通用 Webhook 键
+const Webhook string = "webhook"
+
+// Signer 签名器，使用密钥和当前时间生成时间戳和签名
+type Signer func(secret string, now time.Time) (timestamp int64, sign string, err error)
+
+// LarkSign 飞书机器人签名，将时间戳和密钥拼接后作为 HmacSHA256 的密钥，对空字符串计算签名后进行 Base64 编码
+func LarkSign(secret string, now time.Time) (int64, string, error) {
+	timestamp := now.Unix()
+	hmacSHA256 := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return timestamp, base64.StdEncoding.EncodeToString(hmacSHA256.Sum(nil)), nil
+}
+
+// WebhookData 通用 Webhook 模板数据
+type WebhookData struct {
+	Data      any    // 原始数据，钩子中为日志事件
+	Text      string // 子模板 "text" 渲染后的文本
+	Timestamp int64  // 签名时间戳
+	Sign      string // 签名
+}
+
+// WebhookError 通用 Webhook 响应错误
+type WebhookError struct {
+	Name    string
+	ErrMsg  string
+	ErrCode int
+}
+
+func (w WebhookError) Error() string {
+	return fmt.Sprintf("webhook: failed to send %s: %s (%d)", w.Name, w.ErrMsg, w.ErrCode)
+}
+
+// webhookResponse 兼容飞书和企业微信的响应体
+type webhookResponse struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// WebhookBot 通用 Webhook 机器人，使用模板将数据渲染成 JSON 请求体后推送
+type WebhookBot struct {
+	// 名称，可自定义
+	Name string `json:"name" yaml:"name" toml:"name" long:"name" ini-name:"name"`
+
+	// 推送地址
+	URL string `json:"url" yaml:"url" toml:"url" long:"url" ini-name:"url"`
+
+	// 签名密钥，为空时不签名
+	Secret string `json:"secret" yaml:"secret" toml:"secret" long:"secret" ini-name:"secret"`
+
+	// 全局请求超时时间，值为正时生效
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout" long:"timeout" ini-name:"timeout"`
+
+	// 签名器
+	Signer Signer
+
+	// 请求体模板，如果存在名为 "text" 的子模板，会先将其渲染到 WebhookData.Text 中
+	Template *template.Template
+
+	// 发送请求的客户端，为空时使用 http.DefaultClient
+	Client *http.Client
+}
+
+// Funcs 日志模板中可以使用的函数
+func Funcs() template.FuncMap {
+	return template.FuncMap{"titlef": FirstLine, "prefix": Prefix, "stripmd": stripmd.Strip, "timef": TimeFormat, "json": JSONString}
+}
+
+// JSONString 将值序列化成 JSON 字符串，用于在模板中安全地嵌入文本
+func JSONString(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Parse 解析请求体模板和文本子模板，文本子模板为空时不创建
+func (w *WebhookBot) Parse(body, text string) error {
+	tmpl, err := template.New("").Funcs(Funcs()).Parse(body)
+	if err != nil {
+		return err
+	}
+	if text != "" {
+		_, err = tmpl.New("text").Parse(text)
+		if err != nil {
+			return err
+		}
+	}
+	w.Template = tmpl
+	return nil
+}
+
+// Render 渲染请求体
+func (w *WebhookBot) Render(data any) ([]byte, error) {
+	if w.Template == nil {
+		return nil, fmt.Errorf("webhook: template of %s cannot be nil", w.Name)
+	}
+	d := &WebhookData{Data: data}
+	if w.Secret != "" && w.Signer != nil {
+		var err error
+		d.Timestamp, d.Sign, err = w.Signer(w.Secret, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf := &bytes.Buffer{}
+	if text := w.Template.Lookup("text"); text != nil {
+		err := text.Execute(buf, data)
+		if err != nil {
+			return nil, err
+		}
+		d.Text = buf.String()
+		buf.Reset()
+	}
+	err := w.Template.Execute(buf, d)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Post 携带上下文推送已渲染的请求体
+func (w *WebhookBot) Post(ctx context.Context, body []byte) error {
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	cli := w.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return WebhookError{Name: w.Name, ErrMsg: strings.TrimSpace(string(b)), ErrCode: resp.StatusCode}
+	}
+	var r webhookResponse
+	if json.Unmarshal(b, &r) == nil {
+		if r.Code != 0 {
+			return WebhookError{Name: w.Name, ErrMsg: r.Msg, ErrCode: r.Code}
+		}
+		if r.ErrCode != 0 {
+			return WebhookError{Name: w.Name, ErrMsg: r.ErrMsg, ErrCode: r.ErrCode}
+		}
+	}
+	return nil
+}
+
+// SendWithContext 携带上下文渲染并推送数据
+func (w *WebhookBot) SendWithContext(ctx context.Context, data any) error {
+	body, err := w.Render(data)
+	if err != nil {
+		return err
+	}
+	return w.Post(ctx, body)
+}
+
+// Send 渲染并推送数据
+func (w *WebhookBot) Send(data any) error {
+	return w.SendWithContext(context.Background(), data)
+}
+
+// LarkURL 飞书自定义机器人推送地址前缀
+const LarkURL string = "https://open.feishu.cn/open-apis/bot/v2/hook/"
+
+// LarkText 飞书日志文本模板
+const LarkText string = "{{titlef .}}\n{{if stripmd .Message}}{{stripmd .Message}}\n{{end}}{{timef .Time}}"
+
+// LarkBody 飞书文本消息请求体模板
+const LarkBody string = `{ {{- if .Sign}}"timestamp":"{{.Timestamp}}","sign":{{json .Sign}},{{end}}"msg_type":"text","content":{"text":{{json .Text}}}}`
+
+// NewLarkBot 创建飞书自定义机器人，令牌为推送地址中最后一段，密钥为安全设置中签名校验的密钥
+func NewLarkBot(name, token, secret string) *WebhookBot {
+	w := &WebhookBot{Name: name, URL: LarkURL + token, Secret: secret, Signer: LarkSign}
+	_ = w.Parse(LarkBody, LarkText)
+	return w
+}
+
+// WeComURL 企业微信群机器人推送地址前缀
+const WeComURL string = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="
+
+// WeComText 企业微信日志文本模板
+const WeComText string = "{{if .Data.banner}}{{.Data.banner}}\n{{end}}### {{titlef .}}\n\n{{prefix .Message \"> \"}}\n\n<font color=\"comment\">{{timef .Time}}</font>"
+
+// WeComBody 企业微信 markdown 消息请求体模板
+const WeComBody string = `{"msgtype":"markdown","markdown":{"content":{{json .Text}}}}`
+
+// NewWeComBot 创建企业微信群机器人，企业微信使用推送地址中的 key 作为唯一凭证，不需要额外签名
+func NewWeComBot(name, key string) *WebhookBot {
+	w := &WebhookBot{Name: name, URL: WeComURL + key}
+	_ = w.Parse(WeComBody, WeComText)
+	return w
+}
+
+// WebhookHook 通用 Webhook 机器人钩子
+type WebhookHook struct {
+	*WebhookBot
+	sent   inflight       // 正在推送的消息
+	levels []logrus.Level // 日志等级，为空时视为全部等级
+}
+
+func (w *WebhookHook) Levels() []logrus.Level {
+	if len(w.levels) != 0 {
+		return w.levels
+	}
+	return logrus.AllLevels
+}
+
+// Bound 判断日志事件是否绑定了当前机器人
+func (w *WebhookHook) Bound(entry *logrus.Entry) bool {
+	data, ok := entry.Data[Webhook].(string)
+	return ok && data == w.WebhookBot.Name
+}
+
+// Fire 推送消息，推送失败时会将错误写入日志
+func (w *WebhookHook) Fire(entry *logrus.Entry) error {
+	if !w.Bound(entry) {
+		return nil
+	}
+	body, err := w.WebhookBot.Render(entry)
+	if err != nil {
+		observeDrop(Name(w), "render")
+		return err
+	}
+	w.sent.Add()
+	go func(logger *logrus.Logger, body []byte) {
+		defer w.sent.Done()
+		start := time.Now()
+		err := w.WebhookBot.Post(context.Background(), body)
+		observeSend(Name(w), start, err)
+		if err != nil {
+			logger.Error(err)
+		}
+	}(entry.Logger, body)
+	return nil
+}
+
+var _ logrus.Hook = (*WebhookHook)(nil)
+
+// Wait 等待正在推送的消息完成
+func (w *WebhookHook) Wait() {
+	w.sent.Wait()
+}
+
+// Bind 将当前机器人绑定在日志上
+func (w *WebhookHook) Bind(logger *logrus.Logger) *logrus.Entry {
+	return logger.WithField(Webhook, w.WebhookBot.Name)
+}
+
+// NewWebhookHook 创建通用 Webhook 机器人钩子，日志等级为空时视为全部等级
+func NewWebhookHook(bot *WebhookBot, levels ...logrus.Level) *WebhookHook {
+	return &WebhookHook{WebhookBot: bot, levels: levels}
+}
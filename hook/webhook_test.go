@@ -0,0 +1,164 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testEntry() *logrus.Entry {
+	entry := logrus.NewEntry(logrus.New())
+	entry.Level = logrus.ErrorLevel
+	entry.Time = time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	entry.Message = "**hello**\nworld"
+	entry.Data = logrus.Fields{"title": "标题"}
+	return entry
+}
+
+func TestLarkSign(t *testing.T) {
+	timestamp, sign, err := LarkSign("secret", time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != 1700000000 {
+		t.Errorf("timestamp = %d", timestamp)
+	}
+	if want := "fiWS2+gh28DOydAv7hzONH/mDn9+b1Y4Y5ivXWXy8vA="; sign != want {
+		t.Errorf("sign = %s, want %s", sign, want)
+	}
+}
+
+func TestLarkBody(t *testing.T) {
+	bot := NewLarkBot("lark", "token", "secret")
+	bot.Signer = func(string, time.Time) (int64, string, error) { return 1700000000, "sign", nil }
+	body, err := bot.Render(testEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Timestamp string `json:"timestamp"`
+		Sign      string `json:"sign"`
+		MsgType   string `json:"msg_type"`
+		Content   struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	err = json.Unmarshal(body, &got)
+	if err != nil {
+		t.Fatalf("invalid body %s: %v", body, err)
+	}
+	if got.Timestamp != "1700000000" || got.Sign != "sign" || got.MsgType != "text" {
+		t.Errorf("unexpected body: %s", body)
+	}
+	if want := "[ERROR] 标题\nhello\nworld\n2024-01-02 03:04:05"; got.Content.Text != want {
+		t.Errorf("text = %q, want %q", got.Content.Text, want)
+	}
+
+	// 没有密钥时不签名
+	bot = NewLarkBot("lark", "token", "")
+	body, err = bot.Render(testEntry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsigned map[string]any
+	err = json.Unmarshal(body, &unsigned)
+	if err != nil {
+		t.Fatalf("invalid body %s: %v", body, err)
+	}
+	if _, ok := unsigned["sign"]; ok {
+		t.Errorf("unexpected sign: %s", body)
+	}
+}
+
+func TestWeComBody(t *testing.T) {
+	bot := NewWeComBot("wecom", "key")
+	if bot.URL != WeComURL+"key" {
+		t.Errorf("url = %s", bot.URL)
+	}
+	entry := testEntry()
+	entry.Data["banner"] = "![](banner)"
+	body, err := bot.Render(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Content string `json:"content"`
+		} `json:"markdown"`
+	}
+	err = json.Unmarshal(body, &got)
+	if err != nil {
+		t.Fatalf("invalid body %s: %v", body, err)
+	}
+	want := "![](banner)\n### [ERROR] 标题\n\n> **hello**\n> world\n\n<font color=\"comment\">2024-01-02 03:04:05</font>"
+	if got.MsgType != "markdown" || got.Markdown.Content != want {
+		t.Errorf("content = %q, want %q", got.Markdown.Content, want)
+	}
+}
+
+func TestWebhookPost(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		code   int // 期望的错误码，为零时不应出错
+	}{
+		{"ok", http.StatusOK, `{"code":0,"msg":"success"}`, 0},
+		{"wecom ok", http.StatusOK, `{"errcode":0,"errmsg":"ok"}`, 0},
+		{"not json", http.StatusOK, `ok`, 0},
+		{"status", http.StatusBadGateway, "bad gateway", http.StatusBadGateway},
+		{"lark code", http.StatusOK, `{"code":19021,"msg":"sign match fail"}`, 19021},
+		{"wecom errcode", http.StatusOK, `{"errcode":93000,"errmsg":"invalid webhook url"}`, 93000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("content type = %s", ct)
+				}
+				got, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			bot := &WebhookBot{Name: "test", URL: srv.URL}
+			err := bot.Post(context.Background(), []byte(`{"a":1}`))
+			if string(got) != `{"a":1}` {
+				t.Errorf("body = %s", got)
+			}
+			if tt.code == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var webhookErr WebhookError
+			if !errors.As(err, &webhookErr) || webhookErr.ErrCode != tt.code {
+				t.Errorf("error = %v, want code %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	bot := &WebhookBot{Name: "test", URL: srv.URL, Timeout: 50 * time.Millisecond}
+	err := bot.Post(context.Background(), []byte(`{}`))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want deadline exceeded", err)
+	}
+}
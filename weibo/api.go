@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
 	"strconv"
 	"strings"
@@ -48,7 +50,9 @@ type ProfileInfoResponse struct {
 	Data struct {
 		User struct {
 			ScreenName        string `json:"screen_name"`
+			AvatarHd          string `json:"avatar_hd"`
 			Description       string `json:"description"`
+			FollowersCount    int    `json:"followers_count"`
 			FollowersCountStr string `json:"followers_count_str"`
 			FriendsCount      int    `json:"friends_count"`
 			CoverImagePhone   string `json:"cover_image_phone"`
@@ -84,6 +88,9 @@ type Mymlog struct {
 
 	// 未知参数
 	Feature int `req:"query" default:"0"`
+
+	// 翻页游标，来自上一页响应中的 since_id
+	SinceID string `req:"query:since_id,omitempty"`
 }
 
 func (Mymlog) RawURL() string {
@@ -92,40 +99,118 @@ func (Mymlog) RawURL() string {
 
 var _ req.API = Mymlog{}
 
+// User 微博用户
+type User struct {
+	Idstr      string `json:"idstr"`
+	ScreenName string `json:"screen_name"`
+	AvatarHd   string `json:"avatar_hd"`
+}
+
 type PicInfo struct {
 	URL string `json:"url"`
 }
 
+// Pic 配图，类型为 pic 、 gif 或 livephoto
+type Pic struct {
+	Type    string  `json:"type"`
+	Largest PicInfo `json:"largest"`
+	Video   string  `json:"video"` // 实况照片的视频链接
+}
+
+// 资源的媒体类型
+const (
+	MediaImage     = "image"     // 图片
+	MediaGIF       = "gif"       // 动图
+	MediaLivePhoto = "livephoto" // 实况照片的静态图片
+	MediaLive      = "live"      // 实况照片的视频
+	MediaVideo     = "video"     // 视频
+)
+
+// MediaInfo 视频信息
+type MediaInfo struct {
+	Mp4720PMp4   string `json:"mp4_720p_mp4"`
+	Mp4HdURL     string `json:"mp4_hd_url"`
+	Mp4SdURL     string `json:"mp4_sd_url"`
+	StreamURLHd  string `json:"stream_url_hd"`
+	StreamURL    string `json:"stream_url"`
+	PlaybackList []struct {
+		Meta struct {
+			Label        string `json:"label"`
+			QualityIndex int    `json:"quality_index"`
+		} `json:"meta"`
+		PlayInfo struct {
+			URL    string `json:"url"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		} `json:"play_info"`
+	} `json:"playback_list"`
+}
+
+// BestVideo 选择画质最高的视频链接，优先使用播放列表中分辨率最高的视频
+func (m MediaInfo) BestVideo() string {
+	var best string
+	var pixels, quality int
+	for _, p := range m.PlaybackList {
+		if p.PlayInfo.URL == "" {
+			continue
+		}
+		size := p.PlayInfo.Width * p.PlayInfo.Height
+		if best == "" || size > pixels || (size == pixels && p.Meta.QualityIndex > quality) {
+			best, pixels, quality = p.PlayInfo.URL, size, p.Meta.QualityIndex
+		}
+	}
+	if best != "" {
+		return best
+	}
+	for _, url := range []string{m.Mp4720PMp4, m.Mp4HdURL, m.StreamURLHd, m.Mp4SdURL, m.StreamURL} {
+		if url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// MixMediaInfo 混合媒体，图片和视频按顺序排列
+type MixMediaInfo struct {
+	Items []struct {
+		Type string `json:"type"`
+		Data struct {
+			Pic
+			MediaInfo MediaInfo `json:"media_info"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
 type Mblog struct {
-	CreatedAt string `json:"created_at"`
-	Mid       string `json:"mid"`
-	Mblogid   string `json:"mblogid"`
-	User      struct {
-		Idstr      string `json:"idstr"`
-		ScreenName string `json:"screen_name"`
-		AvatarHd   string `json:"avatar_hd"`
-	} `json:"user"`
-	EditCount int      `json:"edit_count"`
-	Source    string   `json:"source"`
-	PicIds    []string `json:"pic_ids"`
-	PicInfos  map[string]struct {
-		Largest PicInfo `json:"largest"`
-	} `json:"pic_infos,omitempty"`
-	IsTop           int    `json:"isTop,omitempty"`
-	Text            string `json:"text"`
-	TextRaw         string `json:"text_raw"`
-	RegionName      string `json:"region_name"`
-	RetweetedStatus *Mblog `json:"retweeted_status,omitempty"`
+	CreatedAt       string         `json:"created_at"`
+	Mid             string         `json:"mid"`
+	Mblogid         string         `json:"mblogid"`
+	User            User           `json:"user"`
+	EditCount       int            `json:"edit_count"`
+	Source          string         `json:"source"`
+	PicIds          []string       `json:"pic_ids"`
+	PicInfos        map[string]Pic `json:"pic_infos,omitempty"`
+	MixMedia        MixMediaInfo   `json:"mix_media_info,omitempty"`
+	IsTop           int            `json:"isTop,omitempty"`
+	IsLongText      bool           `json:"isLongText"`
+	Text            string         `json:"text"`
+	TextRaw         string         `json:"text_raw"`
+	RegionName      string         `json:"region_name"`
+	RetweetedStatus *Mblog         `json:"retweeted_status,omitempty"`
 	PageInfo        struct {
-		MediaInfo struct {
-			Mp4720PMp4 string `json:"mp4_720p_mp4"`
-		} `json:"media_info"`
+		ObjectType string    `json:"object_type"`
+		MediaInfo  MediaInfo `json:"media_info"`
 	} `json:"page_info,omitempty"`
 	Title struct {
 		Text string `json:"text"`
 	} `json:"title,omitempty"`
 }
 
+// IsLike 判断是否为点赞了的微博
+func (mblog *Mblog) IsLike() bool {
+	return strings.Contains(mblog.Title.Text, "赞过的")
+}
+
 // ToBlog 将微博转换成通用博文模型
 func (mblog *Mblog) ToBlog() *model.Blog {
 	blog := &model.Blog{
@@ -147,21 +232,48 @@ func (mblog *Mblog) ToBlog() *model.Blog {
 		},
 	}
 	// 判断是否为点赞了微博
-	if strings.Contains(blog.Title, "赞过的") {
+	if mblog.IsLike() {
 		blog.Type = "like"
 	}
 	// 解析时间
 	blog.Time, blog.Extra["time_parse_error"] = time.Parse(time.RubyDate, mblog.CreatedAt)
+	// 添加资源并记录媒体类型
+	media := make(map[string]string)
+	add := func(url, typ string) {
+		if _, ok := media[url]; url != "" && !ok {
+			blog.Assets = append(blog.Assets, url)
+			media[url] = typ
+		}
+	}
+	addPic := func(pic Pic) {
+		switch pic.Type {
+		case "gif":
+			add(pic.Largest.URL, MediaGIF)
+		case "livephoto":
+			add(pic.Largest.URL, MediaLivePhoto)
+			add(pic.Video, MediaLive)
+		default:
+			add(pic.Largest.URL, MediaImage)
+		}
+	}
 	// 添加配图
 	for _, picID := range mblog.PicIds {
 		if pic, ok := mblog.PicInfos[picID]; ok {
-			blog.Assets = append(blog.Assets, pic.Largest.URL)
+			addPic(pic)
 		}
 	}
-	// 添加视频
-	if mblog.PageInfo.MediaInfo.Mp4720PMp4 != "" {
-		blog.Assets = append(blog.Assets, mblog.PageInfo.MediaInfo.Mp4720PMp4)
+	// 添加混合媒体
+	for _, item := range mblog.MixMedia.Items {
+		switch item.Type {
+		case "pic":
+			addPic(item.Data.Pic)
+		case "video":
+			add(item.Data.MediaInfo.BestVideo(), MediaVideo)
+		}
 	}
+	// 添加视频
+	add(mblog.PageInfo.MediaInfo.BestVideo(), MediaVideo)
+	blog.Extra["media"] = media
 	// 解析被回复博文
 	if mblog.RetweetedStatus != nil {
 		blog.Reply = mblog.RetweetedStatus.ToBlog()
@@ -169,17 +281,184 @@ func (mblog *Mblog) ToBlog() *model.Blog {
 	return blog
 }
 
-// SetProfileInfo 为博文设置微博博主信息
-func SetProfileInfo(ctx context.Context, blog *model.Blog, jar http.CookieJar) {
+// LongText 获取长微博全文
+type LongText struct {
+	CSRF
+	req.Get
+	http.CookieJar
+
+	// 博文短标识符，即 mblogid
+	ID string `req:"query"`
+}
+
+func (LongText) RawURL() string {
+	return "/ajax/statuses/longtext"
+}
+
+var _ req.API = LongText{}
+
+type LongTextResponse struct {
+	Ok   int `json:"ok"`
+	Data struct {
+		LongTextContent string `json:"longTextContent"`
+	} `json:"data"`
+}
+
+func (r LongTextResponse) Unwrap() error {
+	if r.Ok != 1 {
+		return fmt.Errorf("failed to get longtext: %d", r.Ok)
+	}
+	if r.Data.LongTextContent == "" {
+		return errors.New("failed to get longtext: empty content")
+	}
+	return nil
+}
+
+var _ req.Unwrap = (*LongTextResponse)(nil)
+
+func GetLongText(ctx context.Context, mblogid string, jar http.CookieJar) (r LongTextResponse, err error) {
+	err = session.ResultWithContext(ctx, LongText{ID: mblogid, CookieJar: jar}, &r)
+	return
+}
+
+// StatusShow 获取单条博文
+type StatusShow struct {
+	CSRF
+	req.Get
+	http.CookieJar
+
+	// 博文短标识符，即 mblogid
+	ID string `req:"query"`
+
+	// 是否获取长微博全文
+	IsGetLongText bool `req:"query:isGetLongText,omitempty"`
+}
+
+func (StatusShow) RawURL() string {
+	return "/ajax/statuses/show"
+}
+
+var _ req.API = StatusShow{}
+
+type StatusShowResponse struct {
+	Mblog
+	Ok        int    `json:"ok"`
+	Message   string `json:"message"`
+	ErrorCode int    `json:"error_code"`
+}
+
+// 博文不存在时的错误码
+const ErrCodeStatusNotFound = 20101
+
+// IsDeleted 判断博文是否已被删除，只有博文不存在的错误码或提示才视为删除，风控、限流等其他失败不算
+func (r StatusShowResponse) IsDeleted() bool {
+	if r.Ok == 1 {
+		return false
+	}
+	return r.ErrorCode == ErrCodeStatusNotFound || strings.Contains(r.Message, "不存在") || strings.Contains(r.Message, "删除")
+}
+
+// GetStatus 获取单条博文，博文被删除时不返回错误，其余失败的响应均返回错误
+func GetStatus(ctx context.Context, mblogid string, jar http.CookieJar) (r StatusShowResponse, err error) {
+	err = session.ResultWithContext(ctx, StatusShow{ID: mblogid, CookieJar: jar}, &r)
+	if err == nil && r.Ok != 1 && !r.IsDeleted() {
+		err = fmt.Errorf("failed to get status: %s (%d)", r.Message, r.Ok)
+	}
+	return
+}
+
+// IsTruncated 判断正文是否被截断
+func (mblog *Mblog) IsTruncated() bool {
+	return mblog.IsLongText || strings.Contains(mblog.Text, ">展开<")
+}
+
+// HasLongText 判断博文或被转发博文的正文是否被截断
+func (mblog *Mblog) HasLongText() bool {
+	return mblog.IsTruncated() || (mblog.RetweetedStatus != nil && mblog.RetweetedStatus.HasLongText())
+}
+
+// GetLongStatus 获取带有长微博全文的单条博文，全文的响应中可能仍带有 isLongText 标记，因此只检查展开按钮
+func GetLongStatus(ctx context.Context, mblogid string, jar http.CookieJar) (r StatusShowResponse, err error) {
+	err = session.ResultWithContext(ctx, StatusShow{ID: mblogid, IsGetLongText: true, CookieJar: jar}, &r)
+	if err == nil && (r.Ok != 1 || r.Text == "" || strings.Contains(r.Text, ">展开<")) {
+		err = fmt.Errorf("failed to get long status: %s (%d)", r.Message, r.Ok)
+	}
+	return
+}
+
+// longTextHTML 将长微博接口返回的纯文本转换成超文本
+func longTextHTML(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br />")
+}
+
+// expandText 获取被截断的正文，优先使用单条博文接口保留原始超文本，失败时使用长微博接口的纯文本生成超文本
+func (mblog *Mblog) expandText(ctx context.Context, jar http.CookieJar) error {
+	status, statusErr := GetLongStatus(ctx, mblog.Mblogid, jar)
+	if statusErr == nil {
+		mblog.Text = status.Text
+		mblog.TextRaw = status.TextRaw
+		mblog.IsLongText = false
+		return nil
+	}
+	r, err := GetLongText(ctx, mblog.Mblogid, jar)
+	if err != nil {
+		return errors.Join(statusErr, err)
+	}
+	mblog.Text = longTextHTML(r.Data.LongTextContent)
+	mblog.TextRaw = r.Data.LongTextContent
+	mblog.IsLongText = false
+	return nil
+}
+
+// ExpandLongText 获取被截断的博文和被转发博文的全文
+func (mblog *Mblog) ExpandLongText(ctx context.Context, jar http.CookieJar) error {
+	if mblog.IsTruncated() {
+		err := mblog.expandText(ctx, jar)
+		if err != nil {
+			return err
+		}
+	}
+	if mblog.RetweetedStatus != nil {
+		return mblog.RetweetedStatus.ExpandLongText(ctx, jar)
+	}
+	return nil
+}
+
+// MediaType 获取博文资源的媒体类型，未记录媒体类型的旧博文根据后缀推断
+func MediaType(blog *model.Blog, asset string) string {
+	switch media := blog.Extra["media"].(type) {
+	case map[string]string:
+		if typ, ok := media[asset]; ok {
+			return typ
+		}
+	case map[string]any:
+		// 从数据库读取的扩展字段
+		if typ, ok := media[asset].(string); ok {
+			return typ
+		}
+	}
+	for _, suffix := range []string{".jpg", ".jpeg", ".png"} {
+		if strings.HasSuffix(asset, suffix) {
+			return MediaImage
+		}
+	}
+	if strings.HasSuffix(asset, ".gif") {
+		return MediaGIF
+	}
+	return MediaVideo
+}
+
+// SetProfileInfo 为博文设置微博博主信息，会优先使用缓存
+func (p *ProfileCache) SetProfileInfo(ctx context.Context, blog *model.Blog, jar http.CookieJar) {
 	var r ProfileInfoResponse
-	r, blog.Extra["profile_info_error"] = GetProfileInfo(ctx, blog.UID, jar)
+	r, blog.Extra["profile_info_error"] = p.Get(ctx, blog.UID, jar)
 	blog.Name = r.Data.User.ScreenName
 	blog.Desc = r.Data.User.Description
 	blog.Banner, _, _ = strings.Cut(r.Data.User.CoverImagePhone, ";")
 	blog.Follower = r.Data.User.FollowersCountStr
 	blog.Following = strconv.Itoa(r.Data.User.FriendsCount)
 	if blog.Reply != nil {
-		SetProfileInfo(ctx, blog.Reply, jar)
+		p.SetProfileInfo(ctx, blog.Reply, jar)
 	}
 }
 
@@ -211,19 +490,33 @@ func GetMymlog(ctx context.Context, uid int, jar http.CookieJar) (r MymlogRespon
 	return
 }
 
-var lastSendError time.Time
+// GetMymlogPage 获取指定页的博文，游标为空时只使用页数翻页
+func GetMymlogPage(ctx context.Context, uid, page int, sinceID string, jar http.CookieJar) (r MymlogResponse, err error) {
+	err = session.ResultWithContext(ctx, Mymlog{CookieJar: jar, UID: uid, Page: page, SinceID: sinceID}, &r)
+	return
+}
+
+// NextSinceID 获取下一页的游标，没有下一页时返回空字符串
+func (r MymlogResponse) NextSinceID() string {
+	switch v := r.Data.SinceID.(type) {
+	case string:
+		return v
+	case float64:
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
 
-func GetMymlogIter(ctx context.Context, uid int, jar http.CookieJar) func(yield func(Mblog) bool) {
+// GetMymlogIter 迭代获取博文，获取失败时会调用错误上报
+func GetMymlogIter(ctx context.Context, uid int, jar http.CookieJar, onError func(error)) func(yield func(Mblog) bool) {
 	return func(yield func(Mblog) bool) {
 		r, err := GetMymlog(ctx, uid, jar)
 		if err != nil {
-			now := time.Now()
-			if now.Sub(lastSendError) > 10*time.Minute {
-				bot.WithField("title", "迭代微博出错").Error(err)
-				lastSendError = now
-			} else {
-				logger.Errorln("迭代微博出错:", err)
-			}
+			onError(err)
 			return
 		}
 		for _, mblog := range r.Data.List {
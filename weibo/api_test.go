@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// truncatedRetweet 转发了长微博的博文，被转发博文的正文被截断
+const truncatedRetweet = `{
+	"mid": "1",
+	"mblogid": "A",
+	"user": {"idstr": "1", "screen_name": "me"},
+	"text": "转发 <a href=\"/n/friend\">@friend</a>",
+	"text_raw": "转发 @friend",
+	"retweeted_status": {
+		"mid": "2",
+		"mblogid": "B",
+		"user": {"idstr": "2", "screen_name": "friend"},
+		"isLongText": true,
+		"text": "开头 <img alt=\"[笑]\" src=\"smile.png\"> ...<span class=\"expand\">展开</span>",
+		"text_raw": "开头 [笑] ..."
+	}
+}`
+
+func TestExpandLongTextKeepsHTML(t *testing.T) {
+	f := newFakeWeibo(t)
+	f.Handle("/ajax/statuses/show", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "B" || r.URL.Query().Get("isGetLongText") != "true" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("X-Xsrf-Token") != "token" {
+			t.Errorf("missing xsrf token")
+		}
+		w.Write([]byte(`{"ok":1,"mid":"2","isLongText":true,"text":"开头 <img alt=\"[笑]\" src=\"smile.png\"> 结尾 <a href=\"https://t.cn/x\">链接</a>","text_raw":"开头 [笑] 结尾 链接"}`))
+	})
+	var mblog Mblog
+	if err := json.Unmarshal([]byte(truncatedRetweet), &mblog); err != nil {
+		t.Fatal(err)
+	}
+	if !mblog.HasLongText() || mblog.IsTruncated() {
+		t.Fatal("only the retweeted status should be truncated")
+	}
+	err := mblog.ExpandLongText(context.Background(), f.Jar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mblog.HasLongText() {
+		t.Error("status is still truncated")
+	}
+	blog := mblog.ToBlog()
+	if blog.Content != `转发 <a href="/n/friend">@friend</a>` {
+		t.Errorf("retweet content changed: %s", blog.Content)
+	}
+	if want := `开头 <img alt="[笑]" src="smile.png"> 结尾 <a href="https://t.cn/x">链接</a>`; blog.Reply.Content != want {
+		t.Errorf("reply content = %s, want %s", blog.Reply.Content, want)
+	}
+	if blog.Reply.Plaintext != "开头 [笑] 结尾 链接" {
+		t.Errorf("reply plaintext = %s", blog.Reply.Plaintext)
+	}
+	if n := len(f.Requests("/ajax/statuses/show")); n != 1 {
+		t.Errorf("requested status %d times", n)
+	}
+}
+
+func TestExpandLongTextFallback(t *testing.T) {
+	f := newFakeWeibo(t)
+	f.JSON("/ajax/statuses/show", map[string]any{"ok": 0, "message": "busy"})
+	f.JSON("/ajax/statuses/longtext", map[string]any{"ok": 1, "data": map[string]any{"longTextContent": "开头 [笑] <结尾>\n第二行"}})
+	var mblog Mblog
+	if err := json.Unmarshal([]byte(truncatedRetweet), &mblog); err != nil {
+		t.Fatal(err)
+	}
+	err := mblog.ExpandLongText(context.Background(), f.Jar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mblog.HasLongText() {
+		t.Error("status is still truncated")
+	}
+	blog := mblog.ToBlog()
+	// 超文本由全文生成，不再包含展开按钮
+	if want := "开头 [笑] &lt;结尾&gt;<br />第二行"; blog.Reply.Content != want {
+		t.Errorf("reply content = %s, want %s", blog.Reply.Content, want)
+	}
+	if blog.Reply.Plaintext != "开头 [笑] <结尾>\n第二行" {
+		t.Errorf("reply plaintext = %s", blog.Reply.Plaintext)
+	}
+
+	// 两个接口都失败时返回错误
+	f.JSON("/ajax/statuses/longtext", map[string]any{"ok": 0})
+	mblog = Mblog{}
+	json.Unmarshal([]byte(truncatedRetweet), &mblog)
+	if err := mblog.ExpandLongText(context.Background(), f.Jar()); err == nil {
+		t.Error("expected error when both endpoints fail")
+	}
+}
+
+func TestMediaSelection(t *testing.T) {
+	b, err := os.ReadFile("testdata/mblog_media.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mblog Mblog
+	if err := json.Unmarshal(b, &mblog); err != nil {
+		t.Fatal(err)
+	}
+	// 播放列表中选择分辨率最高且有链接的视频，没有播放列表时按画质顺序选择
+	if got := mblog.MixMedia.Items[1].Data.MediaInfo.BestVideo(); got != "https://f.video.weibocdn.com/mix_1080p.mp4" {
+		t.Errorf("mix media best video = %s", got)
+	}
+	if got := mblog.PageInfo.MediaInfo.BestVideo(); got != "https://f.video.weibocdn.com/page_hd.mp4" {
+		t.Errorf("page info best video = %s", got)
+	}
+	if got := (MediaInfo{}).BestVideo(); got != "" {
+		t.Errorf("empty media best video = %s", got)
+	}
+
+	blog := mblog.ToBlog()
+	want := []struct{ asset, typ string }{
+		{"https://wx1.sinaimg.cn/large/pic1.jpg", MediaImage},
+		{"https://wx1.sinaimg.cn/large/gif1.gif", MediaGIF},
+		{"https://wx1.sinaimg.cn/large/live1.jpg", MediaLivePhoto},
+		{"https://video.weibo.com/media/play?livephoto=live1.mov", MediaLive},
+		{"https://f.video.weibocdn.com/mix_1080p.mp4", MediaVideo},
+		{"https://f.video.weibocdn.com/page_hd.mp4", MediaVideo},
+	}
+	var assets []string
+	for _, w := range want {
+		assets = append(assets, w.asset)
+	}
+	// 混合媒体中重复的配图只保留一次
+	if !slices.Equal(blog.Assets, assets) {
+		t.Errorf("assets = %v, want %v", blog.Assets, assets)
+	}
+	// 从数据库读取后媒体类型为 map[string]any
+	b, err = json.Marshal(blog.Extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := &model.Blog{}
+	if err := json.Unmarshal(b, &stored.Extra); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range want {
+		if got := MediaType(blog, w.asset); got != w.typ {
+			t.Errorf("MediaType(%s) = %s, want %s", w.asset, got, w.typ)
+		}
+		if got := MediaType(stored, w.asset); got != w.typ {
+			t.Errorf("stored MediaType(%s) = %s, want %s", w.asset, got, w.typ)
+		}
+	}
+	// 未记录媒体类型的旧博文根据后缀推断
+	legacy := &model.Blog{Extra: model.Extra{}}
+	for asset, typ := range map[string]string{"a.jpg": MediaImage, "a.png": MediaImage, "a.gif": MediaGIF, "a.mp4": MediaVideo} {
+		if got := MediaType(legacy, asset); got != typ {
+			t.Errorf("legacy MediaType(%s) = %s, want %s", asset, got, typ)
+		}
+	}
+}
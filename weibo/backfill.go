@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+	"gorm.io/gorm"
+)
+
+// Backfill 历史微博回溯配置
+type Backfill struct {
+	Enable bool     `long:"enable" description:"回溯所有目标的历史微博后退出"`
+	Until  string   `long:"until" description:"回溯截止日期，早于该日期的微博不再获取，格式为 2006-01-02"`
+	Limit  int      `long:"limit" description:"每个目标最多新保存的微博数量，已经存在的微博不计入，非正数时不限制"`
+	Delay  Interval `long:"delay" default:"3s-8s" description:"翻页间隔范围"`
+}
+
+// BackfillProgress 回溯进度，重启后从上次完成的页继续，截止日期或数量上限变化时重新开始
+type BackfillProgress struct {
+	UID     int       `gorm:"primaryKey"`     // 目标 UID
+	Until   string    `gorm:"not null"`       // 回溯时使用的截止日期
+	Limit   int       `gorm:"not null"`       // 回溯时使用的数量上限
+	Page    int       `gorm:"not null"`       // 已完成的页数
+	SinceID string    `gorm:"not null"`       // 下一页的游标
+	Count   int       `gorm:"not null"`       // 新保存的微博数量，已经存在的微博不计入
+	Done    bool      `gorm:"not null"`       // 是否已完成
+	Updated time.Time `gorm:"autoUpdateTime"` // 更新时间
+}
+
+// sleep 等待指定时间，上下文结束时返回错误
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// exists 通过 Match 判断博文是否已经保存过
+func exists(db *gorm.DB, blog *model.Blog) (bool, error) {
+	result := db.Scopes(blog.Match).Limit(1).Find(&model.Blog{})
+	return result.RowsAffected != 0, result.Error
+}
+
+// archive 通过 Match 去重后保存博文，不发送通知，返回是否为新博文
+func archive(db *gorm.DB, blog *model.Blog) (bool, error) {
+	found, err := exists(db, blog)
+	if err != nil || found {
+		return false, err
+	}
+	return true, db.Create(blog).Error
+}
+
+// Backfill 按页回溯目标的历史微博，直到截止日期、数量上限或最后一页
+func (w *Watcher) Backfill(ctx context.Context, cfg Backfill) error {
+	var until time.Time
+	if cfg.Until != "" {
+		var err error
+		until, err = time.ParseInLocation(time.DateOnly, cfg.Until, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid backfill until: %w", err)
+		}
+	}
+	// 读取回溯进度
+	p := &BackfillProgress{}
+	err := w.m.DB.FirstOrCreate(p, BackfillProgress{UID: w.UID}).Error
+	if err != nil {
+		return err
+	}
+	// 条件变化后从第一页重新回溯，已经保存的微博会被跳过
+	if p.Until != cfg.Until || p.Limit != cfg.Limit {
+		p.Until, p.Limit = cfg.Until, cfg.Limit
+		p.Page, p.SinceID, p.Done = 0, "", false
+	}
+	if p.Done {
+		w.log.Infof("回溯已完成 (共 %d 条)", p.Count)
+		return nil
+	}
+	w.log.Infof("开始回溯 (第 %d 页 已回溯 %d 条)", p.Page+1, p.Count)
+	for {
+		r, err := GetMymlogPage(ctx, w.UID, p.Page+1, p.SinceID, w.m.Jar)
+		if err != nil {
+			return fmt.Errorf("failed to get page %d: %w", p.Page+1, err)
+		}
+		var saved int
+		for _, mblog := range r.Data.List {
+			if cfg.Limit > 0 && p.Count >= cfg.Limit {
+				p.Done = true
+				break
+			}
+			blog := mblog.ToBlog()
+			// 置顶微博不按时间排列，不参与截止判断
+			if !until.IsZero() && mblog.IsTop != 1 && blog.Time.Before(until) {
+				p.Done = true
+				break
+			}
+			found, err := exists(w.m.DB, blog)
+			if err != nil {
+				w.bot.WithField("title", "微博查询失败").Error(err)
+				continue
+			}
+			if found {
+				continue
+			}
+			// 历史微博的博主信息已经过时，不再补充
+			blog = w.expand(ctx, &mblog, blog)
+			err = w.m.DB.Create(blog).Error
+			if err != nil {
+				w.bot.WithField("title", "微博保存失败").Error(err)
+				continue
+			}
+			saved++
+			p.Count++
+		}
+		p.Page++
+		p.SinceID = r.NextSinceID()
+		if len(r.Data.List) == 0 || p.SinceID == "" || (cfg.Limit > 0 && p.Count >= cfg.Limit) {
+			p.Done = true
+		}
+		err = w.m.DB.Save(p).Error
+		if err != nil {
+			return err
+		}
+		w.log.Infof("回溯第 %d 页 (新增 %d 条 已回溯 %d 条)", p.Page, saved, p.Count)
+		if p.Done {
+			w.log.Infof("回溯完成 (共 %d 条)", p.Count)
+			return nil
+		}
+		err = sleep(ctx, cfg.Delay.Next())
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// mblogOn 创建指定日期发布的博文
+func mblogOn(mid, date string, top bool) Mblog {
+	t, _ := time.ParseInLocation(time.DateOnly, date, time.Local)
+	m := Mblog{Mid: mid, Mblogid: "M" + mid, CreatedAt: t.Add(12 * time.Hour).Format(time.RubyDate)}
+	if top {
+		m.IsTop = 1
+	}
+	return m
+}
+
+// savedMIDs 返回数据库中所有博文的 MID
+func savedMIDs(t *testing.T, m *Monitor) []string {
+	var blogs []model.Blog
+	if err := m.DB.Find(&blogs).Error; err != nil {
+		t.Fatal(err)
+	}
+	var mids []string
+	for _, blog := range blogs {
+		mids = append(mids, blog.MID)
+	}
+	slices.Sort(mids)
+	return mids
+}
+
+func TestBackfill(t *testing.T) {
+	f := newFakeWeibo(t)
+	pages := map[string]struct {
+		list  []Mblog
+		since any
+	}{
+		"":   {[]Mblog{mblogOn("top", "2023-01-01", true), mblogOn("a", "2024-03-03", false), mblogOn("b", "2024-03-02", false)}, "p2"},
+		"p2": {[]Mblog{mblogOn("c", "2024-02-02", false), mblogOn("d", "2024-02-01", false)}, "p3"},
+		"p3": {[]Mblog{mblogOn("e", "2024-01-02", false), mblogOn("f", "2024-01-01", false)}, 0},
+	}
+	fail := true
+	f.Handle("/ajax/statuses/mymblog", func(w http.ResponseWriter, r *http.Request) {
+		since := r.URL.Query().Get("since_id")
+		// 第二页第一次请求失败，模拟中断
+		if since == "p2" && fail {
+			fail = false
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		page := pages[since]
+		json.NewEncoder(w).Encode(map[string]any{"ok": 1, "data": map[string]any{"list": page.list, "since_id": page.since}})
+	})
+	m := newTestMonitor(t, f, "--backfill.until", "2024-02-01", "--backfill.delay", "1ms")
+	w := m.Watchers[0]
+	ctx := context.Background()
+	cursors := func() []string {
+		var since []string
+		for _, r := range f.Requests("/ajax/statuses/mymblog") {
+			since = append(since, r.URL.Query().Get("since_id"))
+		}
+		return since
+	}
+	opened := len(cursors())
+
+	cfg := m.Config.Backfill
+	if err := w.Backfill(ctx, cfg); err == nil {
+		t.Fatal("interrupted backfill should fail")
+	}
+	var p BackfillProgress
+	m.DB.First(&p, w.UID)
+	if p.Page != 1 || p.SinceID != "p2" || p.Count != 3 || p.Done {
+		t.Errorf("progress after interruption = %+v", p)
+	}
+
+	// 从中断的页继续，置顶博文不参与截止判断，早于截止日期时停止
+	if err := w.Backfill(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursors()[opened:]; !slices.Equal(got, []string{"", "p2", "p2", "p3"}) {
+		t.Errorf("requested cursors %v", got)
+	}
+	if got := savedMIDs(t, m); !slices.Equal(got, []string{"a", "b", "c", "d", "top"}) {
+		t.Errorf("saved %v", got)
+	}
+
+	// 条件不变时不再请求
+	requested := len(cursors())
+	if err := w.Backfill(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors()) != requested {
+		t.Error("finished backfill requested again")
+	}
+
+	// 条件变化后重新开始，已经存在的博文不计入数量上限
+	cfg.Until, cfg.Limit = "", 6
+	if err := w.Backfill(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cursors()[requested:]; !slices.Equal(got, []string{"", "p2", "p3"}) {
+		t.Errorf("requested cursors after change %v", got)
+	}
+	if got := savedMIDs(t, m); !slices.Equal(got, []string{"a", "b", "c", "d", "e", "top"}) {
+		t.Errorf("saved after change %v", got)
+	}
+	m.DB.First(&p, w.UID)
+	if p.Until != "" || p.Limit != 6 || p.Count != 6 || !p.Done {
+		t.Errorf("progress after change = %+v", p)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Drelf2018/exp/hook"
+	"github.com/Drelf2018/req/cookie"
+	"github.com/playwright-community/playwright-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Page 浏览器访问页面的结果
+type Page struct {
+	URL           string         // 访问的链接
+	Cookies       []*http.Cookie // 页面加载后的 Cookie
+	Screenshot    []byte         // 页面截图
+	ScreenshotErr error          // 截图失败的原因
+}
+
+// Browser 浏览器，携带 Cookie 访问页面，等待 ready 返回真后获取 Cookie 和截图
+type Browser interface {
+	Visit(ctx context.Context, rawURL string, cookies []*http.Cookie, ready func([]*http.Cookie) bool) (*Page, error)
+	Close() error
+}
+
+// PlaywrightBrowser 使用 playwright 驱动的 Chromium 浏览器
+type PlaywrightBrowser struct {
+	Playwright *playwright.Playwright
+	Browser    playwright.Browser
+	Poll       time.Duration // 轮询 Cookie 的间隔
+	Timeout    time.Duration // 等待页面加载的超时时间
+	Log        logrus.FieldLogger
+}
+
+// optionalCookies 转换成 playwright 的 Cookie ，没有域名的 Cookie 属于访问的链接
+func optionalCookies(rawURL string, cookies []*http.Cookie) []playwright.OptionalCookie {
+	r := make([]playwright.OptionalCookie, 0, len(cookies))
+	for _, c := range cookies {
+		o := playwright.OptionalCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			HttpOnly: playwright.Bool(c.HttpOnly),
+			Secure:   playwright.Bool(c.Secure),
+		}
+		if c.Domain == "" {
+			o.URL = playwright.String(rawURL)
+		} else {
+			o.Domain = playwright.String(c.Domain)
+			o.Path = playwright.String(c.Path)
+			if c.Path == "" {
+				o.Path = playwright.String("/")
+			}
+		}
+		if !c.Expires.IsZero() {
+			o.Expires = playwright.Float(float64(c.Expires.Unix()))
+		}
+		r = append(r, o)
+	}
+	return r
+}
+
+// httpCookies 转换成 http.Cookie
+func httpCookies(cookies []playwright.Cookie) []*http.Cookie {
+	r := make([]*http.Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		c := &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+		// 会话 Cookie 的过期时间为 -1
+		if cookie.Expires > 0 {
+			c.Expires = time.Unix(int64(cookie.Expires), 0)
+		}
+		r = append(r, c)
+	}
+	return r
+}
+
+func (b *PlaywrightBrowser) Visit(ctx context.Context, rawURL string, cookies []*http.Cookie, ready func([]*http.Cookie) bool) (*Page, error) {
+	// 创建浏览器上下文
+	b.Log.Debug("创建浏览器上下文")
+	browserContext, err := b.Browser.NewContext()
+	if err != nil {
+		return nil, fmt.Errorf("could not create context: %w", err)
+	}
+	defer browserContext.Close()
+	// 添加 Cookie
+	b.Log.Debug("添加 Cookie")
+	err = browserContext.AddCookies(optionalCookies(rawURL, cookies))
+	if err != nil {
+		return nil, fmt.Errorf("could not add cookies: %w", err)
+	}
+	// 新建页面
+	b.Log.Debug("新建页面")
+	page, err := browserContext.NewPage()
+	if err != nil {
+		return nil, fmt.Errorf("could not create page: %w", err)
+	}
+	defer page.Close()
+	b.Log.Debugln("访问页面:", rawURL)
+	_, err = page.Goto(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not goto: %w", err)
+	}
+	// 轮询等待页面加载后获取 Cookie
+	b.Log.Debug("轮询等待页面加载后获取 Cookie")
+	ctx, cancel := context.WithTimeout(ctx, b.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(b.Poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for cookie timeout: %w", ctx.Err())
+		case <-ticker.C:
+			b.Log.Debug("开始获取 Cookie")
+			pwCookies, err := browserContext.Cookies(rawURL)
+			if err != nil {
+				return nil, fmt.Errorf("cookie not found: %w", err)
+			}
+			result := &Page{URL: rawURL, Cookies: httpCookies(pwCookies)}
+			if ready != nil && !ready(result.Cookies) {
+				continue
+			}
+			result.Screenshot, result.ScreenshotErr = page.Screenshot()
+			return result, nil
+		}
+	}
+}
+
+func (b *PlaywrightBrowser) Close() error {
+	return errors.Join(b.Browser.Close(), b.Playwright.Stop())
+}
+
+var _ Browser = (*PlaywrightBrowser)(nil)
+
+// NewPlaywrightBrowser 安装并启动 playwright 驱动的 Chromium 浏览器
+func NewPlaywrightBrowser(logger *logrus.Logger) (*PlaywrightBrowser, error) {
+	logger.Info("安装 playwright")
+	err := playwright.Install(&playwright.RunOptions{Verbose: true, Logger: hook.NewSlog(logger)})
+	if err != nil {
+		return nil, fmt.Errorf("could not install playwright: %w", err)
+	}
+	logger.Info("启动 playwright")
+	pw, err := playwright.Run()
+	if err != nil {
+		return nil, fmt.Errorf("could not start playwright: %w", err)
+	}
+	logger.Info("启动 Chromium 浏览器")
+	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
+		Args: []string{
+			"--no-sandbox",
+			"--disable-dev-shm-usage",
+			"--disable-features=AutomationControlled",
+		},
+	})
+	if err != nil {
+		pw.Stop()
+		return nil, fmt.Errorf("could not launch chromium: %w", err)
+	}
+	return &PlaywrightBrowser{Playwright: pw, Browser: browser, Poll: 10 * time.Second, Timeout: 40 * time.Second, Log: logger}, nil
+}
+
+// FakeBrowser 不访问网络的浏览器，返回预设的页面，用于测试刷新流程
+type FakeBrowser struct {
+	Page   Page  // 预设的页面
+	Err    error // 预设的错误
+	mu     sync.Mutex
+	visits []string
+}
+
+func (f *FakeBrowser) Visit(ctx context.Context, rawURL string, cookies []*http.Cookie, ready func([]*http.Cookie) bool) (*Page, error) {
+	f.mu.Lock()
+	f.visits = append(f.visits, rawURL)
+	f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if ready != nil && !ready(f.Page.Cookies) {
+		return nil, fmt.Errorf("waiting for cookie timeout: %w", context.DeadlineExceeded)
+	}
+	page := f.Page
+	page.URL = rawURL
+	return &page, nil
+}
+
+func (f *FakeBrowser) Close() error {
+	return nil
+}
+
+// Visits 获取访问过的链接
+func (f *FakeBrowser) Visits() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.visits...)
+}
+
+var _ Browser = (*FakeBrowser)(nil)
+
+// HasCookie 生成判断是否存在指定 Cookie 的函数
+func HasCookie(name string) func([]*http.Cookie) bool {
+	return func(cookies []*http.Cookie) bool {
+		for _, c := range cookies {
+			if c.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// BrowserRefresher 使用浏览器依次访问主页刷新 Cookie 的刷新器
+type BrowserRefresher struct {
+	Browser   Browser
+	BaseURL   *url.URL                                    // Cookie 所属的链接
+	Homepages []string                                    // 轮流访问的主页
+	Ready     func([]*http.Cookie) bool                   // 判断页面是否加载完成
+	Validate  func(context.Context, http.CookieJar) error // 校验刷新后的 Cookie ，为空时不校验
+	OnPage    func(context.Context, *Page)                // 刷新成功后处理页面，例如上传截图
+	Log       logrus.FieldLogger
+	next      atomic.Uint64
+}
+
+// IsValid 每次检测时都认定已失效
+func (r *BrowserRefresher) IsValid(context.Context, http.CookieJar) (bool, error) {
+	return false, nil
+}
+
+func (r *BrowserRefresher) Refresh(ctx context.Context, jar http.CookieJar) error {
+	if len(r.Homepages) == 0 {
+		return errors.New("no homepage")
+	}
+	homepage := r.Homepages[(r.next.Add(1)-1)%uint64(len(r.Homepages))]
+	r.Log.Debugln("刷新 Cookie:", homepage)
+	cookies := jar.Cookies(r.BaseURL)
+	for _, c := range cookies {
+		c.Domain, c.Path = "."+r.BaseURL.Hostname(), "/"
+	}
+	page, err := r.Browser.Visit(ctx, homepage, cookies, r.Ready)
+	if err != nil {
+		return err
+	}
+	r.Log.Debug("设置 Cookie")
+	jar.SetCookies(r.BaseURL, page.Cookies)
+	if r.Validate != nil {
+		err = r.Validate(ctx, jar)
+		if err != nil {
+			return fmt.Errorf("invalid cookie: %w", err)
+		}
+	}
+	if r.OnPage != nil {
+		r.OnPage(ctx, page)
+	}
+	return nil
+}
+
+var _ cookie.Refresher = (*BrowserRefresher)(nil)
+
+// ValidateMymlog 生成通过获取博主的博文校验 Cookie 的函数
+func ValidateMymlog(uid int) func(context.Context, http.CookieJar) error {
+	return func(ctx context.Context, jar http.CookieJar) error {
+		_, err := GetMymlog(ctx, uid, jar)
+		return err
+	}
+}
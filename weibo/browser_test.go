@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestRefresher(browser *FakeBrowser) *BrowserRefresher {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	base, _ := url.Parse("https://weibo.com")
+	return &BrowserRefresher{
+		Browser:   browser,
+		BaseURL:   base,
+		Homepages: []string{"https://weibo.com/u/1", "https://weibo.com/u/2"},
+		Ready:     HasCookie("XSRF-TOKEN"),
+		Log:       logger,
+	}
+}
+
+func TestBrowserRefresher(t *testing.T) {
+	browser := &FakeBrowser{Page: Page{
+		Cookies:    []*http.Cookie{{Name: "SUB", Value: "sub"}, {Name: "XSRF-TOKEN", Value: "token"}},
+		Screenshot: []byte("jpg"),
+	}}
+	r := newTestRefresher(browser)
+	var pages []string
+	r.OnPage = func(ctx context.Context, page *Page) {
+		if string(page.Screenshot) != "jpg" {
+			t.Errorf("unexpected screenshot: %q", page.Screenshot)
+		}
+		pages = append(pages, page.URL)
+	}
+	var validated int
+	r.Validate = func(ctx context.Context, jar http.CookieJar) error {
+		validated++
+		if len(jar.Cookies(r.BaseURL)) != 2 {
+			t.Errorf("cookies not set before validation: %v", jar.Cookies(r.BaseURL))
+		}
+		return nil
+	}
+	jar, _ := cookiejar.New(nil)
+	for i := 0; i < 3; i++ {
+		if err := r.Refresh(context.Background(), jar); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 依次轮流访问主页
+	want := []string{"https://weibo.com/u/1", "https://weibo.com/u/2", "https://weibo.com/u/1"}
+	if visits := browser.Visits(); !slices.Equal(visits, want) {
+		t.Errorf("visits = %v, want %v", visits, want)
+	}
+	if !slices.Equal(pages, want) || validated != 3 {
+		t.Errorf("pages = %v, validated %d times", pages, validated)
+	}
+	if valid, _ := r.IsValid(context.Background(), jar); valid {
+		t.Error("browser refresher should always refresh")
+	}
+}
+
+func TestBrowserRefresherError(t *testing.T) {
+	onPage := func(t *testing.T) func(context.Context, *Page) {
+		return func(context.Context, *Page) { t.Error("OnPage called after failure") }
+	}
+	t.Run("ready", func(t *testing.T) {
+		// 页面一直没有 XSRF-TOKEN 时等待超时
+		r := newTestRefresher(&FakeBrowser{Page: Page{Cookies: []*http.Cookie{{Name: "SUB", Value: "sub"}}}})
+		r.OnPage = onPage(t)
+		jar, _ := cookiejar.New(nil)
+		err := r.Refresh(context.Background(), jar)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want deadline exceeded", err)
+		}
+		if len(jar.Cookies(r.BaseURL)) != 0 {
+			t.Error("cookies set after timeout")
+		}
+	})
+	t.Run("validate", func(t *testing.T) {
+		r := newTestRefresher(&FakeBrowser{Page: Page{Cookies: []*http.Cookie{{Name: "XSRF-TOKEN", Value: "token"}}}})
+		r.OnPage = onPage(t)
+		r.Validate = func(context.Context, http.CookieJar) error { return errors.New("ok -100") }
+		jar, _ := cookiejar.New(nil)
+		err := r.Refresh(context.Background(), jar)
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid cookie: ok -100") {
+			t.Errorf("error = %v", err)
+		}
+	})
+	t.Run("visit", func(t *testing.T) {
+		browser := &FakeBrowser{Err: errors.New("crashed")}
+		r := newTestRefresher(browser)
+		r.OnPage = onPage(t)
+		jar, _ := cookiejar.New(nil)
+		if err := r.Refresh(context.Background(), jar); err != browser.Err {
+			t.Errorf("error = %v", err)
+		}
+	})
+	t.Run("homepage", func(t *testing.T) {
+		r := newTestRefresher(&FakeBrowser{})
+		r.Homepages = nil
+		jar, _ := cookiejar.New(nil)
+		if err := r.Refresh(context.Background(), jar); err == nil {
+			t.Error("expected error without homepage")
+		}
+	})
+}
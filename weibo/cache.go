@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// 共享的博主信息请求的超时时间，请求不会随调用者的上下文取消
+const profileFetchTimeout = 30 * time.Second
+
+// CachedProfile 持久化的博主信息缓存
+type CachedProfile struct {
+	UID     string              `gorm:"primaryKey"`      // 博主标识符
+	Data    ProfileInfoResponse `gorm:"serializer:json"` // 博主信息
+	Expires time.Time           // 过期时间
+}
+
+// ProfileCache 博主信息缓存，同时获取同一博主的信息时只会请求一次，缓存会持久化到数据库中
+type ProfileCache struct {
+	TTL   time.Duration // 缓存有效期
+	DB    *gorm.DB      // 持久化的数据库，为空时只在内存中缓存
+	Log   logrus.FieldLogger
+	mu    sync.Mutex
+	items map[string]CachedProfile
+	group singleflight.Group
+}
+
+// load 从内存或数据库中读取未过期的缓存
+func (p *ProfileCache) load(uid string) (r ProfileInfoResponse, ok bool) {
+	now := time.Now()
+	p.mu.Lock()
+	item, ok := p.items[uid]
+	p.mu.Unlock()
+	if ok && now.Before(item.Expires) {
+		return item.Data, true
+	}
+	if p.DB == nil {
+		return r, false
+	}
+	result := p.DB.Where("uid = ? AND expires > ?", uid, now).Limit(1).Find(&item)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return r, false
+	}
+	p.mu.Lock()
+	p.items[uid] = item
+	p.mu.Unlock()
+	return item.Data, true
+}
+
+// Get 获取博主信息，缓存过期时重新请求，取消上下文只会让当前调用返回，不会中断其他调用共享的请求
+func (p *ProfileCache) Get(ctx context.Context, uid string, jar http.CookieJar) (ProfileInfoResponse, error) {
+	if r, ok := p.load(uid); ok {
+		return r, nil
+	}
+	ch := p.group.DoChan(uid, func() (any, error) {
+		// 等待期间其他请求可能已经写入缓存
+		if r, ok := p.load(uid); ok {
+			return r, nil
+		}
+		fetch, cancel := context.WithTimeout(context.WithoutCancel(ctx), profileFetchTimeout)
+		defer cancel()
+		r, err := GetProfileInfo(fetch, uid, jar)
+		if err != nil {
+			return r, err
+		}
+		p.Put(uid, r)
+		return r, nil
+	})
+	select {
+	case <-ctx.Done():
+		return ProfileInfoResponse{}, ctx.Err()
+	case res := <-ch:
+		return res.Val.(ProfileInfoResponse), res.Err
+	}
+}
+
+// Put 写入缓存
+func (p *ProfileCache) Put(uid string, r ProfileInfoResponse) {
+	item := CachedProfile{UID: uid, Data: r, Expires: time.Now().Add(p.TTL)}
+	p.mu.Lock()
+	p.items[uid] = item
+	p.mu.Unlock()
+	if p.DB != nil {
+		err := p.DB.Save(&item).Error
+		if err != nil {
+			p.Log.Warnln("保存博主信息缓存失败:", err)
+		}
+	}
+}
+
+// Invalidate 清除缓存
+func (p *ProfileCache) Invalidate(uid string) {
+	p.mu.Lock()
+	delete(p.items, uid)
+	p.mu.Unlock()
+	if p.DB != nil {
+		err := p.DB.Delete(&CachedProfile{UID: uid}).Error
+		if err != nil {
+			p.Log.Warnln("清除博主信息缓存失败:", err)
+		}
+	}
+}
+
+// NewProfileCache 创建博主信息缓存
+func NewProfileCache(ttl time.Duration, db *gorm.DB, log logrus.FieldLogger) *ProfileCache {
+	return &ProfileCache{TTL: ttl, DB: db, Log: log, items: make(map[string]CachedProfile)}
+}
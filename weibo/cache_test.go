@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// profileHandler 返回请求次数作为昵称的博主信息接口
+func profileHandler(f *fakeWeibo, release <-chan struct{}) {
+	var mu sync.Mutex
+	var n int
+	f.Handle("/ajax/profile/info", func(w http.ResponseWriter, r *http.Request) {
+		if release != nil {
+			<-release
+		}
+		mu.Lock()
+		n++
+		name := fmt.Sprintf("%s-%d", r.URL.Query().Get("uid"), n)
+		mu.Unlock()
+		fmt.Fprintf(w, `{"ok":1,"data":{"user":{"screen_name":%q}}}`, name)
+	})
+}
+
+func TestProfileCacheTTL(t *testing.T) {
+	f := newFakeWeibo(t)
+	profileHandler(f, nil)
+	p := NewProfileCache(50*time.Millisecond, nil, logrus.New())
+	ctx := context.Background()
+	for _, want := range []string{"1-1", "1-1"} {
+		r, err := p.Get(ctx, "1", f.Jar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Data.User.ScreenName; got != want {
+			t.Errorf("screen name = %s, want %s", got, want)
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	r, err := p.Get(ctx, "1", f.Jar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Data.User.ScreenName; got != "1-2" {
+		t.Errorf("screen name after expiry = %s, want 1-2", got)
+	}
+}
+
+func TestProfileCacheCoalesce(t *testing.T) {
+	f := newFakeWeibo(t)
+	release := make(chan struct{})
+	profileHandler(f, release)
+	p := NewProfileCache(time.Hour, nil, logrus.New())
+	// 第一个调用者取消后，共享请求仍然继续
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := p.Get(ctx, "1", f.Jar())
+		first <- err
+	}()
+	for len(f.Requests("/ajax/profile/info")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var wg sync.WaitGroup
+	names := make([]string, 4)
+	for i := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := p.Get(context.Background(), "1", f.Jar())
+			if err != nil {
+				t.Error(err)
+			}
+			names[i] = r.Data.User.ScreenName
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller error = %v", err)
+	}
+	close(release)
+	wg.Wait()
+	for _, name := range names {
+		if name != "1-1" {
+			t.Errorf("screen name = %s, want 1-1", name)
+		}
+	}
+	if n := len(f.Requests("/ajax/profile/info")); n != 1 {
+		t.Errorf("requested %d times, want 1", n)
+	}
+}
+
+func TestProfileCachePersist(t *testing.T) {
+	f := newFakeWeibo(t)
+	profileHandler(f, nil)
+	db, err := gorm.Open(sqlite.Open("file:" + t.Name() + "?mode=memory&cache=shared"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&CachedProfile{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	get := func(p *ProfileCache) string {
+		r, err := p.Get(ctx, "1", f.Jar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Data.User.ScreenName
+	}
+	if got := get(NewProfileCache(time.Hour, db, logrus.New())); got != "1-1" {
+		t.Errorf("screen name = %s, want 1-1", got)
+	}
+	// 新建的缓存从数据库读取
+	p := NewProfileCache(time.Hour, db, logrus.New())
+	if got := get(p); got != "1-1" {
+		t.Errorf("screen name from database = %s, want 1-1", got)
+	}
+	// 清除后内存和数据库中都不再存在
+	p.Invalidate("1")
+	if got := get(NewProfileCache(time.Hour, db, logrus.New())); got != "1-2" {
+		t.Errorf("screen name after invalidate = %s, want 1-2", got)
+	}
+	p.Invalidate("1")
+	if got := get(p); got != "1-3" {
+		t.Errorf("screen name after invalidate = %s, want 1-3", got)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+	"github.com/Drelf2018/req"
+)
+
+// BuildComments 获取评论
+type BuildComments struct {
+	CSRF
+	req.Get
+	http.CookieJar
+
+	// 博文标识符，获取二级评论时为一级评论标识符
+	ID string `req:"query"`
+
+	// 博主标识符
+	UID string `req:"query"`
+
+	// 评论层级，0 为一级评论，1 为二级评论
+	FetchLevel int `req:"query" default:"0"`
+
+	// 翻页游标，来自上一页响应中的 max_id
+	MaxID int64 `req:"query,omitempty"`
+
+	// 每页数量
+	Count int `req:"query" default:"20"`
+
+	// 未知参数
+	IsReload       int `req:"query" default:"1"`
+	IsShowBulletin int `req:"query" default:"2"`
+	IsMix          int `req:"query" default:"0"`
+}
+
+func (BuildComments) RawURL() string {
+	return "/ajax/statuses/buildComments"
+}
+
+var _ req.API = BuildComments{}
+
+type Comment struct {
+	CreatedAt    string    `json:"created_at"`
+	Idstr        string    `json:"idstr"`
+	Rootidstr    string    `json:"rootidstr"`
+	Text         string    `json:"text"`
+	TextRaw      string    `json:"text_raw"`
+	Source       string    `json:"source"`
+	User         User      `json:"user"`
+	LikeCounts   int       `json:"like_counts"`
+	TotalNumber  int       `json:"total_number"`
+	ReplyComment *Comment  `json:"reply_comment,omitempty"`
+	Comments     []Comment `json:"comments,omitempty"`
+}
+
+// ToBlog 将评论转换成通用博文模型，root 为评论所属的已保存博文
+func (c *Comment) ToBlog(root *model.Blog) *model.Blog {
+	blog := &model.Blog{
+		UID:       c.User.Idstr,
+		Name:      c.User.ScreenName,
+		Avatar:    c.User.AvatarHd,
+		MID:       c.Idstr,
+		URL:       root.URL,
+		Site:      "weibo.com",
+		Type:      "comment",
+		Source:    c.Source,
+		Version:   "0",
+		Content:   c.Text,
+		Plaintext: c.TextRaw,
+		BlogID:    &root.ID,
+		Extra: model.Extra{
+			"like_counts": c.LikeCounts,
+			"root_id":     c.Rootidstr,
+		},
+	}
+	blog.Time, blog.Extra["time_parse_error"] = time.Parse(time.RubyDate, c.CreatedAt)
+	return blog
+}
+
+type CommentsResponse struct {
+	Ok          int       `json:"ok"`
+	Data        []Comment `json:"data"`
+	MaxID       int64     `json:"max_id"`
+	TotalNumber int       `json:"total_number"`
+}
+
+func (r CommentsResponse) Unwrap() error {
+	if r.Ok != 1 {
+		return fmt.Errorf("failed to get comments: %d", r.Ok)
+	}
+	return nil
+}
+
+var _ req.Unwrap = (*CommentsResponse)(nil)
+
+// GetComments 获取一页评论，level 为 0 时 id 为博文标识符，为 1 时 id 为一级评论标识符
+func GetComments(ctx context.Context, id, uid string, level int, maxID int64, jar http.CookieJar) (r CommentsResponse, err error) {
+	err = session.ResultWithContext(ctx, BuildComments{CookieJar: jar, ID: id, UID: uid, FetchLevel: level, MaxID: maxID}, &r)
+	return
+}
+
+// Comments 评论刷新配置
+type Comments struct {
+	Crontab string   `long:"crontab" description:"刷新近期微博评论任务，为空时不启用"`
+	Days    int      `long:"days" default:"3" description:"刷新最近几天内发布的微博"`
+	Pages   int      `long:"pages" default:"5" description:"每条微博或一级评论最多获取的页数"`
+	Delay   Interval `long:"delay" default:"1s-3s" description:"请求间隔范围"`
+}
+
+// walkComments 逐页获取评论，直到最后一页或页数上限
+func walkComments(ctx context.Context, cfg Comments, id, uid string, level int, jar http.CookieJar, yield func(Comment) error) error {
+	var maxID int64
+	for page := 0; cfg.Pages <= 0 || page < cfg.Pages; page++ {
+		if page != 0 {
+			err := sleep(ctx, cfg.Delay.Next())
+			if err != nil {
+				return err
+			}
+		}
+		r, err := GetComments(ctx, id, uid, level, maxID, jar)
+		if err != nil {
+			return err
+		}
+		for _, c := range r.Data {
+			err = yield(c)
+			if err != nil {
+				return err
+			}
+		}
+		if r.MaxID == 0 || len(r.Data) == 0 {
+			return nil
+		}
+		maxID = r.MaxID
+	}
+	return nil
+}
+
+// refreshBlogComments 保存博文的一级评论和二级评论，返回新增评论数量
+func (w *Watcher) refreshBlogComments(ctx context.Context, cfg Comments, root *model.Blog) (saved int, err error) {
+	save := func(blog *model.Blog) error {
+		ok, err := archive(w.m.DB, blog)
+		if ok {
+			saved++
+		}
+		return err
+	}
+	err = walkComments(ctx, cfg, root.MID, root.UID, 0, w.m.Jar, func(c Comment) error {
+		parent := c.ToBlog(root)
+		err := save(parent)
+		if err != nil {
+			return err
+		}
+		if c.TotalNumber == 0 {
+			return nil
+		}
+		err = sleep(ctx, cfg.Delay.Next())
+		if err != nil {
+			return err
+		}
+		// 二级评论回复一级评论，或者回复同一楼层的另一条二级评论
+		return walkComments(ctx, cfg, c.Idstr, root.UID, 1, w.m.Jar, func(r Comment) error {
+			reply := r.ToBlog(root)
+			if r.ReplyComment != nil && r.ReplyComment.Idstr != c.Idstr {
+				reply.Reply = r.ReplyComment.ToBlog(root)
+			} else {
+				reply.Reply = c.ToBlog(root)
+			}
+			return save(reply)
+		})
+	})
+	return
+}
+
+// RefreshComments 刷新目标近期微博的评论，评论只保存不通知
+func (w *Watcher) RefreshComments(ctx context.Context, cfg Comments) {
+	var blogs []*model.Blog
+	err := w.m.DB.Where("uid = ? AND site = ? AND type = ? AND time > ?", strconv.Itoa(w.UID), "weibo.com", "blog", time.Now().AddDate(0, 0, -cfg.Days)).
+		Order("id DESC").
+		Find(&blogs).Error
+	if err != nil {
+		w.bot.WithField("title", "微博查询失败").Error(err)
+		return
+	}
+	var total int
+	visited := make(map[string]struct{})
+	for i, blog := range blogs {
+		// 同一博文的多个编辑版本只使用最新的版本
+		if _, ok := visited[blog.MID]; ok {
+			continue
+		}
+		visited[blog.MID] = struct{}{}
+		if i != 0 && sleep(ctx, cfg.Delay.Next()) != nil {
+			return
+		}
+		saved, err := w.refreshBlogComments(ctx, cfg, blog)
+		total += saved
+		if err != nil {
+			w.bot.WithField("title", "评论刷新失败").Error(err)
+			continue
+		}
+		w.log.Debugf("刷新评论 %s (新增 %d 条)", blog.URL, saved)
+	}
+	w.log.Infof("刷新评论完成 (微博 %d 条 新增评论 %d 条)", len(visited), total)
+}
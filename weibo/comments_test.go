@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// commentPage 一页评论
+type commentPage struct {
+	list  []Comment
+	maxID int64
+}
+
+// commentAt 创建评论
+func commentAt(id string, replies int, reply *Comment) Comment {
+	return Comment{
+		CreatedAt:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local).Format(time.RubyDate),
+		Idstr:        id,
+		TextRaw:      "评论 " + id,
+		User:         User{Idstr: "2"},
+		TotalNumber:  replies,
+		ReplyComment: reply,
+	}
+}
+
+func TestRefreshComments(t *testing.T) {
+	f := newFakeWeibo(t)
+	reply := commentAt("r1", 0, nil)
+	// 键为 fetch_level/id/max_id
+	pages := map[string]commentPage{
+		"0/100/":  {[]Comment{commentAt("c1", 2, nil), commentAt("c2", 0, nil)}, 5},
+		"0/100/5": {[]Comment{commentAt("c3", 0, nil)}, 0},
+		"1/c1/":   {[]Comment{reply}, 9},
+		"1/c1/9":  {[]Comment{commentAt("r2", 0, &reply)}, 9},
+		"1/c1/10": {nil, 0},
+	}
+	f.Handle("/ajax/statuses/buildComments", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		page, ok := pages[q.Get("fetch_level")+"/"+q.Get("id")+"/"+q.Get("max_id")]
+		if !ok {
+			t.Errorf("unexpected request: %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"ok": 1, "data": page.list, "max_id": page.maxID})
+	})
+	m := newTestMonitor(t, f, "--comments.delay", "1ms", "--comments.pages", "2")
+	root := &model.Blog{UID: "1", MID: "100", URL: "https://weibo.com/1/M100", Site: "weibo.com", Type: "blog", Version: "0", Time: time.Now(), Extra: model.Extra{}}
+	if err := m.DB.Create(root).Error; err != nil {
+		t.Fatal(err)
+	}
+	w := m.Watchers[0]
+	ctx := context.Background()
+	w.RefreshComments(ctx, m.Config.Comments)
+
+	// 按 max_id 翻页，二级评论达到页数上限后停止
+	var cursors []string
+	for _, r := range f.Requests("/ajax/statuses/buildComments") {
+		q := r.URL.Query()
+		cursors = append(cursors, q.Get("fetch_level")+"/"+q.Get("id")+"/"+q.Get("max_id"))
+	}
+	if want := []string{"0/100/", "1/c1/", "1/c1/9", "0/100/5"}; !slices.Equal(cursors, want) {
+		t.Errorf("requests = %v, want %v", cursors, want)
+	}
+	var comments []model.Blog
+	err := m.DB.Where("type = ?", "comment").Order("id").Find(&comments).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyTo := make(map[string]string)
+	for _, c := range comments {
+		if c.BlogID == nil || *c.BlogID != root.ID {
+			t.Errorf("comment %s does not belong to root", c.MID)
+		}
+		if c.ReplyID != nil {
+			var parent model.Blog
+			m.DB.First(&parent, *c.ReplyID)
+			replyTo[c.MID] = parent.MID
+		}
+	}
+	if len(comments) != 5 {
+		t.Errorf("saved %d comments, want 5", len(comments))
+	}
+	// 二级评论回复一级评论或同一楼层的另一条二级评论
+	if replyTo["r1"] != "c1" || replyTo["r2"] != "r1" {
+		t.Errorf("replies = %v", replyTo)
+	}
+
+	// 再次刷新时已经保存的评论不重复保存
+	w.RefreshComments(ctx, m.Config.Comments)
+	var n int64
+	m.DB.Model(&model.Blog{}).Where("type = ?", "comment").Count(&n)
+	if n != 5 {
+		t.Errorf("saved %d comments after refresh, want 5", n)
+	}
+}
@@ -1,167 +1,133 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/playwright-community/playwright-go"
-	"github.com/qiniu/go-sdk/v7/storagev2/uploader"
 	"github.com/sirupsen/logrus"
 )
 
-var browser playwright.Browser
-
-// WeiboHomepage 用于上下文中存取微博主页的键
-type WeiboHomepage struct{}
-
-// RefreshWeiboCookie 使用 playwright 访问微博主页刷新微博 Cookie ，会尝试从上下文中读取要访问的微博主页
-func RefreshWeiboCookie(ctx context.Context, jar http.CookieJar) error {
-	// 创建浏览器上下文
-	logger.Debug("创建浏览器上下文")
-	browserContext, err := browser.NewContext()
-	if err != nil {
-		return fmt.Errorf("could not create context: %w", err)
-	}
-	defer browserContext.Close()
-	// 添加 Cookie
-	logger.Debug("添加 Cookie")
-	cookies := make([]playwright.OptionalCookie, 0)
-	for _, cookie := range jar.Cookies(session.BaseURL) {
-		cookies = append(cookies, playwright.OptionalCookie{
-			Name:   cookie.Name,
-			Value:  cookie.Value,
-			Domain: playwright.String("." + session.BaseURL.Host),
-			Path:   playwright.String("/"),
-		})
+// UploadScreenshot 上传刷新 Cookie 时的页面截图，并通知刷新成功
+func (m *Monitor) UploadScreenshot(ctx context.Context, page *Page) {
+	if m.Storage == nil {
+		m.Bot.WithField("title", "微博刷新成功").Info()
+		return
 	}
-	err = browserContext.AddCookies(cookies)
-	if err != nil {
-		return fmt.Errorf("could not add cookies: %w", err)
+	if page.ScreenshotErr != nil {
+		m.Bot.WithField("title", "微博截屏失败").Error(page.ScreenshotErr)
+		return
 	}
-	// 新建页面
-	logger.Debug("新建页面")
-	page, err := browserContext.NewPage()
+	filename := fmt.Sprintf("weibo_%s.jpg", time.Now().Format("2006_01_02_15_04_05"))
+	err := m.Storage.Put(ctx, filename, page.Screenshot, http.DetectContentType(page.Screenshot), m.Config.Storage.Expiry)
 	if err != nil {
-		return fmt.Errorf("could not create page: %w", err)
-	}
-	defer page.Close()
-	// 访问微博主页实现 Cookie 刷新
-	logger.Debug("访问微博主页实现 Cookie 刷新")
-	homepage := "https://weibo.com/u/7198559139"
-	if value := ctx.Value(WeiboHomepage{}); value != nil {
-		if v, ok := value.(string); ok {
-			homepage = v
-		}
-	}
-	_, err = page.Goto(homepage)
-	if err != nil {
-		return fmt.Errorf("could not goto: %w", err)
-	}
-	// 轮询等待页面加载后获取 Cookie
-	logger.Debug("轮询等待页面加载后获取 Cookie")
-	ctx, cancel := context.WithTimeout(ctx, 40*time.Second)
-	defer cancel()
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("waiting for cookie timeout: %w", ctx.Err())
-		case <-ticker.C:
-			logger.Debug("开始获取 Cookie")
-			pwCookies, err := browserContext.Cookies(homepage)
-			if err != nil {
-				return fmt.Errorf("cookie not found: %w", err)
-			}
-			var hasToken bool
-			cookies := make([]*http.Cookie, 0, len(pwCookies))
-			for _, cookie := range pwCookies {
-				logger.Debugln(cookie.Name, "=", cookie.Value)
-				if cookie.Name == "XSRF-TOKEN" {
-					hasToken = true
-				}
-				cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
-			}
-			// 缺少 XSRF-TOKEN
-			if !hasToken {
-				logger.Debug("缺少 XSRF-TOKEN")
-				continue
-			}
-			logger.Debug("设置 Cookie")
-			jar.SetCookies(session.BaseURL, cookies)
-			_, err = GetMymlog(ctx, 7198559139, jar)
-			if err != nil {
-				return fmt.Errorf("invalid cookie: %w", err)
-			}
-			img, err := page.Screenshot()
-			if err != nil {
-				bot.WithField("title", "微博截屏失败").Error(err)
-				return nil
-			}
-			filename := fmt.Sprintf("weibo_%s.jpg", time.Now().Format("2006_01_02_15_04_05"))
-			err = qiniu.UploadReader(ctx, bytes.NewReader(img), &uploader.ObjectOptions{
-				BucketName: bucket.Name(),
-				ObjectName: &filename,
-				FileName:   filename,
-			}, nil)
-			if err != nil {
-				bot.WithField("title", "截屏上传失败").Error(err)
-				return nil
-			}
-			bot.WithFields(logrus.Fields{
-				"banner": fmt.Sprintf("![](https://yun.nana7mi.link/%s)", filename),
-				"title":  "微博刷新成功",
-			}).Info()
-			err = bucket.Object(filename).SetLifeCycle().DeleteAfterDays(3).Call(context.Background())
-			if err != nil {
-				bot.WithField("title", "设置过期失败").Error(err)
-			}
-			return nil
-		}
+		m.Bot.WithField("title", "截屏上传失败").Error(err)
+		return
 	}
+	m.Bot.WithFields(logrus.Fields{
+		"banner": fmt.Sprintf("![](%s)", m.Storage.URL(filename)),
+		"title":  "微博刷新成功",
+	}).Info()
 }
 
-// CookieJar 每次设置 Cookie 时将其保存在本地
+// CookieJar 每次设置 Cookie 时将其结构化地保存在本地
 type CookieJar struct {
 	http.CookieJar
-	UID int
+	UID   int
+	Store *CookieStore
+	Log   *logrus.Entry // 保存失败时使用的日志
 }
 
 func (c *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
 	// 更新底层 http.CookieJar
 	c.CookieJar.SetCookies(u, cookies)
 	// 更新本地 Cookie
-	req := &http.Request{Header: make(http.Header)}
-	for _, cookie := range c.CookieJar.Cookies(session.BaseURL) {
-		req.AddCookie(cookie)
+	err := c.Store.Update(u, cookies)
+	if err != nil {
+		c.Log.WithField("title", "保存 Cookie 失败").Error(err)
+	}
+}
+
+// Restore 将记录恢复到底层 http.CookieJar 中
+func (c *CookieJar) Restore(records []CookieRecord) {
+	for _, r := range records {
+		c.CookieJar.SetCookies(r.URL(), []*http.Cookie{r.Cookie()})
 	}
-	err := os.WriteFile(strconv.Itoa(c.UID)+".cookie", []byte(req.Header.Get("Cookie")), os.ModePerm)
+}
+
+// importLegacy 导入旧版本保存的 Cookie 请求头
+func (c *CookieJar) importLegacy(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	cookies, err := http.ParseCookie(strings.TrimSpace(string(b)))
 	if err != nil {
-		bot.WithField("title", "保存 Cookie 失败").Error(err)
+		return err
 	}
+	c.SetCookies(session.BaseURL, cookies)
+	return nil
 }
 
-func NewCookieJar(uid int) (*CookieJar, error) {
+// importNetscape 导入 Netscape 格式的 Cookie 文件
+func (c *CookieJar) importNetscape(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := ReadNetscape(f)
+	if err != nil {
+		return err
+	}
+	c.Store.Add(records...)
+	c.Restore(records)
+	return c.Store.Save()
+}
+
+// NewCookieJar 读取本地保存的 Cookie ，文件不存在时会尝试导入旧版本的 <uid>.cookie 文件。
+// 迁移信息写入 logger ，保存失败时使用绑定了机器人的 log 通知
+func NewCookieJar(uid int, opts CookieOptions, logger logrus.FieldLogger, log *logrus.Entry) (*CookieJar, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, err
 	}
-	k := &CookieJar{CookieJar: jar, UID: uid}
-	b, err := os.ReadFile(strconv.Itoa(uid) + ".cookie")
+	path := opts.File
+	if path == "" {
+		path = strconv.Itoa(uid) + ".json"
+	}
+	store, err := NewCookieStore(path, opts.Format, opts.Key)
 	if err != nil {
 		return nil, err
 	}
-	cookies, err := http.ParseCookie(string(b))
-	if err != nil {
+	k := &CookieJar{CookieJar: jar, UID: uid, Store: store, Log: log}
+	err = store.Load()
+	switch {
+	case err == nil:
+		k.Restore(store.Records())
+	case errors.Is(err, os.ErrNotExist):
+		legacy := strconv.Itoa(uid) + ".cookie"
+		err = k.importLegacy(legacy)
+		if err == nil {
+			logger.Infof("已将 %s 迁移至 %s", legacy, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to import %s: %w", legacy, err)
+		}
+	default:
 		return nil, err
 	}
-	jar.SetCookies(session.BaseURL, cookies)
+	if opts.Import != "" {
+		err = k.importNetscape(opts.Import)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", opts.Import, err)
+		}
+	}
 	return k, nil
 }
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieOptions Cookie 持久化配置
+type CookieOptions struct {
+	File   string `long:"file" description:"Cookie 文件路径，为空时使用 <me>.json"`
+	Format string `long:"format" default:"json" choice:"json" choice:"netscape" description:"Cookie 文件格式"`
+	Key    string `long:"key" description:"Cookie 文件加密密钥，为空时不加密"`
+	Import string `long:"import" description:"启动时导入的 Netscape 格式 Cookie 文件"`
+}
+
+// CookieRecord 结构化的 Cookie 记录
+type CookieRecord struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`    // 域名
+	HostOnly bool      `json:"host_only"` // 是否只对域名本身有效，不包括子域名
+	Path     string    `json:"path"`      // 路径
+	Expires  time.Time `json:"expires"`   // 过期时间，零值表示会话 Cookie
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+}
+
+// key 记录的唯一键
+func (r CookieRecord) key() string {
+	return r.Domain + ";" + r.Path + ";" + r.Name
+}
+
+// Expired 判断记录是否已经过期
+func (r CookieRecord) Expired(now time.Time) bool {
+	return !r.Expires.IsZero() && !r.Expires.After(now)
+}
+
+// URL 设置 Cookie 时使用的链接
+func (r CookieRecord) URL() *url.URL {
+	scheme := "http"
+	if r.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: r.Domain, Path: r.Path}
+}
+
+// Cookie 转换成 http.Cookie
+func (r CookieRecord) Cookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     r.Name,
+		Value:    r.Value,
+		Path:     r.Path,
+		Expires:  r.Expires,
+		Secure:   r.Secure,
+		HttpOnly: r.HttpOnly,
+	}
+	if !r.HostOnly {
+		c.Domain = r.Domain
+	}
+	return c
+}
+
+// NewCookieRecord 根据设置 Cookie 时使用的链接创建记录
+func NewCookieRecord(u *url.URL, c *http.Cookie, now time.Time) CookieRecord {
+	r := CookieRecord{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   strings.TrimPrefix(strings.ToLower(c.Domain), "."),
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+	if r.Domain == "" {
+		r.Domain = u.Hostname()
+		r.HostOnly = true
+	}
+	if r.Path == "" || r.Path[0] != '/' {
+		r.Path = "/"
+	}
+	if c.MaxAge > 0 {
+		r.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	} else if c.MaxAge < 0 {
+		r.Expires = now
+	}
+	return r
+}
+
+// WriteNetscape 以 Netscape cookies.txt 格式写入记录
+func WriteNetscape(w io.Writer, records []CookieRecord) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n")
+	for _, r := range records {
+		domain := r.Domain
+		if !r.HostOnly {
+			domain = "." + domain
+		}
+		if r.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !r.Expires.IsZero() {
+			expires = r.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!r.HostOnly), r.Path, netscapeBool(r.Secure), expires, r.Name, r.Value)
+	}
+	return bw.Flush()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// ReadNetscape 读取 Netscape cookies.txt 格式的记录
+func ReadNetscape(r io.Reader) ([]CookieRecord, error) {
+	var records []CookieRecord
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		var httpOnly bool
+		if rest, ok := strings.CutPrefix(text, "#HttpOnly_"); ok {
+			text, httpOnly = rest, true
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid netscape cookie at line %d", line)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid netscape cookie expires at line %d: %w", line, err)
+		}
+		record := CookieRecord{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.TrimPrefix(fields[0], "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires != 0 {
+			record.Expires = time.Unix(expires, 0)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// WriteFileAtomic 原子地写入文件，先写入同目录下的临时文件再重命名
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// CookieStore 结构化保存 Cookie 的文件，可以使用 AES-GCM 加密
+type CookieStore struct {
+	Path    string      // 文件路径
+	Format  string      // 文件格式，可选值为 json 和 netscape
+	aead    cipher.AEAD // 加密器，为空时不加密
+	mu      sync.Mutex  // 记录锁
+	save    sync.Mutex  // 写入锁，保证快照和写入的顺序一致，避免旧的快照覆盖新的快照
+	records map[string]CookieRecord
+}
+
+// encrypt 加密数据，密文前附加随机数
+func (s *CookieStore) encrypt(data []byte) ([]byte, error) {
+	if s.aead == nil {
+		return data, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// decrypt 解密数据
+func (s *CookieStore) decrypt(data []byte) ([]byte, error) {
+	if s.aead == nil {
+		return data, nil
+	}
+	size := s.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("cookie file too short")
+	}
+	plain, err := s.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cookie file: %w", err)
+	}
+	return plain, nil
+}
+
+// Records 按域名、路径和名称排序获取未过期的记录
+func (s *CookieStore) Records() []CookieRecord {
+	now := time.Now()
+	s.mu.Lock()
+	records := make([]CookieRecord, 0, len(s.records))
+	for _, r := range s.records {
+		if !r.Expired(now) {
+			records = append(records, r)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].key() < records[j].key()
+	})
+	return records
+}
+
+// Add 添加记录，已过期的记录会删除同名记录
+func (s *CookieStore) Add(records ...CookieRecord) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range records {
+		if r.Expired(now) {
+			delete(s.records, r.key())
+		} else {
+			s.records[r.key()] = r
+		}
+	}
+}
+
+// Load 读取文件中的记录，文件不存在时返回 os.ErrNotExist
+func (s *CookieStore) Load() error {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return err
+	}
+	b, err = s.decrypt(b)
+	if err != nil {
+		return err
+	}
+	var records []CookieRecord
+	if s.Format == "netscape" {
+		records, err = ReadNetscape(bytes.NewReader(b))
+	} else {
+		err = json.Unmarshal(b, &records)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse cookie file: %w", err)
+	}
+	s.Add(records...)
+	return nil
+}
+
+// Save 原子地将未过期的记录写入文件，文件权限为 0600
+func (s *CookieStore) Save() error {
+	s.save.Lock()
+	defer s.save.Unlock()
+	records := s.Records()
+	var b []byte
+	var err error
+	if s.Format == "netscape" {
+		buf := &bytes.Buffer{}
+		err = WriteNetscape(buf, records)
+		b = buf.Bytes()
+	} else {
+		b, err = json.MarshalIndent(records, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	b, err = s.encrypt(b)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.Path, b, 0600)
+}
+
+// Match 判断记录能否由该域名设置
+func (r CookieRecord) Match(host string) bool {
+	host = strings.ToLower(host)
+	if r.HostOnly {
+		return host == r.Domain
+	}
+	return host == r.Domain || strings.HasSuffix(host, "."+r.Domain)
+}
+
+// Update 根据设置 Cookie 时使用的链接更新记录并保存，会忽略其他域名的 Cookie
+func (s *CookieStore) Update(u *url.URL, cookies []*http.Cookie) error {
+	now := time.Now()
+	records := make([]CookieRecord, 0, len(cookies))
+	for _, c := range cookies {
+		if r := NewCookieRecord(u, c, now); r.Match(u.Hostname()) {
+			records = append(records, r)
+		}
+	}
+	s.Add(records...)
+	return s.Save()
+}
+
+// NewCookieStore 创建 Cookie 文件，密钥不为空时使用其 SHA-256 摘要作为 AES-256-GCM 密钥
+func NewCookieStore(path, format, key string) (*CookieStore, error) {
+	s := &CookieStore{Path: path, Format: format, records: make(map[string]CookieRecord)}
+	if key != "" {
+		sum := sha256.Sum256([]byte(key))
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return nil, err
+		}
+		s.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
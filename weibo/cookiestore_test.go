@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRecords 覆盖域 Cookie 、仅主机 Cookie 、会话 Cookie 和 HttpOnly 的记录
+func testRecords() []CookieRecord {
+	expires := time.Unix(time.Now().Add(24*time.Hour).Unix(), 0)
+	return []CookieRecord{
+		{Name: "SUB", Value: "sub", Domain: "weibo.com", Path: "/", Expires: expires, Secure: true, HttpOnly: true},
+		{Name: "XSRF-TOKEN", Value: "token", Domain: "weibo.com", HostOnly: true, Path: "/"},
+		{Name: "SUBP", Value: "subp", Domain: "passport.weibo.com", Path: "/visitor", Expires: expires},
+	}
+}
+
+// equalRecords 比较记录，过期时间只比较时刻
+func equalRecords(a, b []CookieRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if !x.Expires.Equal(y.Expires) {
+			return false
+		}
+		x.Expires, y.Expires = time.Time{}, time.Time{}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNetscapeRoundTrip(t *testing.T) {
+	records := testRecords()
+	var buf bytes.Buffer
+	if err := WriteNetscape(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[1] != "#HttpOnly_.weibo.com\tTRUE\t/\tTRUE\t"+strconv.FormatInt(records[0].Expires.Unix(), 10)+"\tSUB\tsub" {
+		t.Errorf("unexpected line: %q", lines[1])
+	}
+	if lines[2] != "weibo.com\tFALSE\t/\tFALSE\t0\tXSRF-TOKEN\ttoken" {
+		t.Errorf("unexpected line: %q", lines[2])
+	}
+	got, err := ReadNetscape(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalRecords(got, records) {
+		t.Errorf("round trip = %+v, want %+v", got, records)
+	}
+	if _, err := ReadNetscape(strings.NewReader("weibo.com\tFALSE\t/\n")); err == nil {
+		t.Error("expected error for invalid line")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "cookie.json")
+	if err := os.WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(name, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(name)
+	info, err := os.Stat(name)
+	if err != nil || string(b) != "new" || info.Mode().Perm() != 0600 {
+		t.Errorf("content = %q, mode = %v, err = %v", b, info.Mode(), err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
+
+func TestCookieStoreEncrypt(t *testing.T) {
+	for _, format := range []string{"json", "netscape"} {
+		t.Run(format, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "cookie")
+			s, err := NewCookieStore(name, format, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Add(testRecords()...)
+			if err := s.Save(); err != nil {
+				t.Fatal(err)
+			}
+			b, _ := os.ReadFile(name)
+			if bytes.Contains(b, []byte("XSRF-TOKEN")) {
+				t.Error("cookie file is not encrypted")
+			}
+			if info, _ := os.Stat(name); info.Mode().Perm() != 0600 {
+				t.Errorf("mode = %v", info.Mode())
+			}
+			loaded, _ := NewCookieStore(name, format, "key")
+			if err := loaded.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if !equalRecords(loaded.Records(), s.Records()) {
+				t.Errorf("loaded %+v, want %+v", loaded.Records(), s.Records())
+			}
+			// 密钥错误或未加密读取时失败
+			wrong, _ := NewCookieStore(name, format, "other")
+			if err := wrong.Load(); err == nil {
+				t.Error("expected error with wrong key")
+			}
+			plain, _ := NewCookieStore(name, format, "")
+			if err := plain.Load(); err == nil {
+				t.Error("expected error without key")
+			}
+		})
+	}
+}
+
+func TestCookieStoreUpdate(t *testing.T) {
+	s, _ := NewCookieStore(filepath.Join(t.TempDir(), "cookie.json"), "json", "")
+	u, _ := url.Parse("https://weibo.com/ajax")
+	err := s.Update(u, []*http.Cookie{
+		{Name: "XSRF-TOKEN", Value: "token"},
+		{Name: "SUB", Value: "sub", Domain: ".weibo.com", MaxAge: 60},
+		{Name: "OTHER", Value: "x", Domain: "example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Records(); len(r) != 2 || r[0].Name != "SUB" || r[0].HostOnly || !r[1].HostOnly || r[1].Path != "/" {
+		t.Errorf("unexpected records: %+v", r)
+	}
+	// 过期的 Cookie 会删除记录
+	s.Update(u, []*http.Cookie{{Name: "SUB", Domain: ".weibo.com", MaxAge: -1}})
+	if r := s.Records(); len(r) != 1 || r[0].Name != "XSRF-TOKEN" {
+		t.Errorf("unexpected records: %+v", r)
+	}
+}
+
+func TestCookieStoreConcurrentSave(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "cookie.json")
+	s, _ := NewCookieStore(name, "json", "")
+	u, _ := url.Parse("https://weibo.com/")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Update(u, []*http.Cookie{{Name: "C" + strconv.Itoa(i), Value: "v"}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// 最后写入的文件包含全部记录
+	loaded, _ := NewCookieStore(name, "json", "")
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(loaded.Records()); n != 20 {
+		t.Errorf("saved %d records, want 20", n)
+	}
+}
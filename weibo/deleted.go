@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// tracked 时间线上出现过的博文
+type tracked struct {
+	Mblogid string    // 博文短标识符
+	Time    time.Time // 发布时间
+}
+
+// detectDeleted 比较本次和之前时间线上的博文，检测被删除的博文。
+// 置顶博文和点赞的博文不参与检测，早于本次时间线上最早博文的博文视为翻页漂移，不再跟踪
+func (w *Watcher) detectDeleted(ctx context.Context, list []Mblog) {
+	if w.tracked == nil {
+		w.tracked = make(map[string]tracked)
+	}
+	current := make(map[string]tracked)
+	var cutoff time.Time
+	for i := range list {
+		mblog := &list[i]
+		if mblog.IsTop == 1 || mblog.IsLike() {
+			continue
+		}
+		t, err := time.Parse(time.RubyDate, mblog.CreatedAt)
+		if err != nil {
+			continue
+		}
+		current[mblog.Mid] = tracked{Mblogid: mblog.Mblogid, Time: t}
+		if cutoff.IsZero() || t.Before(cutoff) {
+			cutoff = t
+		}
+	}
+	for mid, t := range w.tracked {
+		if _, ok := current[mid]; ok {
+			continue
+		}
+		// 被新博文挤出时间线
+		if !cutoff.IsZero() && t.Time.Before(cutoff) {
+			delete(w.tracked, mid)
+			continue
+		}
+		// 仍在跟踪范围内却消失了，直接查询博文确认是否被删除
+		r, err := GetStatus(ctx, t.Mblogid, w.m.Jar)
+		if err != nil {
+			// 查询失败时下次轮询再确认
+			w.log.Debugf("查询微博 %s 失败: %v", t.Mblogid, err)
+			continue
+		}
+		delete(w.tracked, mid)
+		if !r.IsDeleted() {
+			w.log.Debugf("微博 %s 不在时间线上", t.Mblogid)
+			continue
+		}
+		w.markDeleted(ctx, mid)
+	}
+	for mid, t := range current {
+		w.tracked[mid] = t
+	}
+}
+
+// markDeleted 将已保存的最新版本标记为已删除，并发送类型为 delete 的通知
+func (w *Watcher) markDeleted(ctx context.Context, mid string) {
+	var blog model.Blog
+	result := w.m.DB.Preload("Reply").
+		Where("mid = ? AND site = ? AND type = ?", mid, "weibo.com", "blog").
+		Order("id DESC").
+		Limit(1).
+		Find(&blog)
+	if result.Error != nil {
+		w.bot.WithField("title", "微博查询失败").Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	if blog.Extra == nil {
+		blog.Extra = model.Extra{}
+	}
+	blog.Extra["deleted"] = time.Now()
+	err := w.m.DB.Model(&blog).Select("Extra").Updates(&blog).Error
+	if err != nil {
+		w.bot.WithField("title", "微博保存失败").Error(err)
+	}
+	w.log.Infoln("删除微博:", blog)
+	sendBlog := blog
+	sendBlog.Type = "delete"
+	sendBlog.Title = "删除了微博"
+	w.notify(ctx, &sendBlog)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// mblogAt 创建指定时间发布的博文
+func mblogAt(mid string, hour, minute int, top bool) Mblog {
+	m := Mblog{Mid: mid, Mblogid: "M" + mid, CreatedAt: time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local).Format(time.RubyDate)}
+	if top {
+		m.IsTop = 1
+	}
+	return m
+}
+
+func TestDetectDeleted(t *testing.T) {
+	f := newFakeWeibo(t)
+	m := newTestMonitor(t, f)
+	f.Handle("/ajax/statuses/show", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("id") {
+		case "Mc":
+			w.Write([]byte(`{"ok":0,"message":"该微博不存在","error_code":20101}`))
+		case "Mx":
+			// 风控时的响应不是删除
+			w.Write([]byte(`{"ok":-100,"url":"https://passport.weibo.com/sso/signin"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	err := m.DB.Create(&model.Blog{UID: "1", MID: "c", Site: "weibo.com", Type: "blog", Extra: model.Extra{}}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := m.Watchers[0]
+	ctx := context.Background()
+	w.detectDeleted(ctx, []Mblog{
+		mblogAt("p", 6, 0, true),
+		mblogAt("b", 10, 0, false),
+		mblogAt("x", 9, 30, false),
+		mblogAt("c", 9, 0, false),
+		mblogAt("d", 8, 0, false),
+		mblogAt("f", 7, 30, false),
+	})
+	if _, ok := w.tracked["p"]; ok {
+		t.Error("pinned status should not be tracked")
+	}
+	// 本次时间线最早的博文为 d ，因此 f 视为翻页漂移，置顶博文不影响判断
+	w.detectDeleted(ctx, []Mblog{
+		mblogAt("p", 6, 0, true),
+		mblogAt("e", 11, 0, false),
+		mblogAt("b", 10, 0, false),
+		mblogAt("d", 8, 0, false),
+	})
+	var queried []string
+	for _, r := range f.Requests("/ajax/statuses/show") {
+		queried = append(queried, r.URL.Query().Get("id"))
+	}
+	slices.Sort(queried)
+	if !slices.Equal(queried, []string{"Mc", "Mx"}) {
+		t.Errorf("queried %v, want [Mc Mx]", queried)
+	}
+	var mids []string
+	for mid := range w.tracked {
+		mids = append(mids, mid)
+	}
+	slices.Sort(mids)
+	// 查询失败的 x 继续跟踪，下次轮询再确认
+	if !slices.Equal(mids, []string{"b", "d", "e", "x"}) {
+		t.Errorf("tracked %v, want [b d e x]", mids)
+	}
+	var blog model.Blog
+	err = m.DB.Where("mid = ?", "c").First(&blog).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := blog.Extra["deleted"]; !ok {
+		t.Error("deleted status was not marked")
+	}
+}
+
+func TestStatusIsDeleted(t *testing.T) {
+	for _, c := range []struct {
+		r    StatusShowResponse
+		want bool
+	}{
+		{StatusShowResponse{Ok: 1, Mblog: Mblog{Mid: "1"}}, false},
+		{StatusShowResponse{Ok: 0, ErrorCode: ErrCodeStatusNotFound}, true},
+		{StatusShowResponse{Ok: 0, Message: "抱歉，此微博已被作者删除"}, true},
+		{StatusShowResponse{Ok: -100}, false},
+		{StatusShowResponse{Ok: 0, Message: "请求过于频繁"}, false},
+	} {
+		if got := c.r.IsDeleted(); got != c.want {
+			t.Errorf("IsDeleted(%d, %d, %s) = %v, want %v", c.r.Ok, c.r.ErrorCode, c.r.Message, got, c.want)
+		}
+	}
+}
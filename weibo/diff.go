@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// maxDiffCells 逐字比较时动态规划表的最大尺寸，超过时直接整体替换
+const maxDiffCells = 4 << 20
+
+// Diff 逐字比较文本，删除的部分用 [-x-] 标记，新增的部分用 {+y+} 标记
+func Diff(old, new string) string {
+	a, b := []rune(old), []rune(new)
+	// 去除相同的前缀和后缀
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	sb := &strings.Builder{}
+	sb.WriteString(string(a[:prefix]))
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		writeChange(sb, x, y)
+	} else {
+		diffLCS(sb, x, y)
+	}
+	sb.WriteString(string(a[len(a)-suffix:]))
+	return sb.String()
+}
+
+// writeChange 写入一处修改
+func writeChange(sb *strings.Builder, removed, added []rune) {
+	if len(removed) != 0 {
+		sb.WriteString("[-")
+		sb.WriteString(string(removed))
+		sb.WriteString("-]")
+	}
+	if len(added) != 0 {
+		sb.WriteString("{+")
+		sb.WriteString(string(added))
+		sb.WriteString("+}")
+	}
+}
+
+// diffLCS 使用最长公共子序列比较文本
+func diffLCS(sb *strings.Builder, a, b []rune) {
+	// lcs[i][j] 表示 a[i:] 和 b[j:] 的最长公共子序列长度
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var removed, added []rune
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			writeChange(sb, removed, added)
+			removed, added = removed[:0], added[:0]
+			sb.WriteRune(a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	writeChange(sb, removed, added)
+}
+
+// DiffAssets 比较资源链接，返回新增和移除的资源
+func DiffAssets(old, new []string) (added, removed []string) {
+	oldSet := make(map[string]struct{}, len(old))
+	for _, asset := range old {
+		oldSet[asset] = struct{}{}
+	}
+	newSet := make(map[string]struct{}, len(new))
+	for _, asset := range new {
+		newSet[asset] = struct{}{}
+		if _, ok := oldSet[asset]; !ok {
+			added = append(added, asset)
+		}
+	}
+	for _, asset := range old {
+		if _, ok := newSet[asset]; !ok {
+			removed = append(removed, asset)
+		}
+	}
+	return
+}
+
+// EditedBlog 根据同一博文的上一个版本生成类型为 edit 的通知博文，正文为逐字比较结果，资源只保留新增的资源
+func EditedBlog(prev, blog *model.Blog) *model.Blog {
+	added, removed := DiffAssets(prev.Assets, blog.Assets)
+	sb := &strings.Builder{}
+	sb.WriteString(Diff(prev.Plaintext, blog.Plaintext))
+	if len(added) != 0 {
+		fmt.Fprintf(sb, "\n\n新增 %d 个资源", len(added))
+	}
+	if len(removed) != 0 {
+		fmt.Fprintf(sb, "\n\n移除 %d 个资源", len(removed))
+		for _, asset := range removed {
+			sb.WriteString("\n")
+			sb.WriteString(asset)
+		}
+	}
+	edited := *blog
+	edited.Type = "edit"
+	edited.Title = fmt.Sprintf("编辑了微博 (第 %s 版)", blog.Version)
+	edited.Plaintext = sb.String()
+	edited.Assets = added
+	edited.Extra = model.Extra{
+		"previous_id":      prev.ID,
+		"previous_version": prev.Version,
+		"added":            added,
+		"removed":          removed,
+	}
+	for k, v := range blog.Extra {
+		if _, ok := edited.Extra[k]; !ok {
+			edited.Extra[k] = v
+		}
+	}
+	return &edited
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+func TestDiff(t *testing.T) {
+	long := strings.Repeat("ab", 1100)
+	for _, c := range []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "今天天气很好", "今天天气很好", "今天天气很好"},
+		{"empty", "", "", ""},
+		{"insert", "今天很好", "今天天气很好", "今天{+天气+}很好"},
+		{"delete", "今天天气很好", "今天很好", "今天[-天气-]很好"},
+		{"replace", "今天天气很好", "今天心情很好", "今天[-天气-]{+心情+}很好"},
+		{"append", "hello", "hello, 世界", "hello{+, 世界+}"},
+		{"multiple", "我在北京吃饭", "你在上海吃饭", "[-我-]{+你+}在[-北京-]{+上海+}吃饭"},
+		{"emoji", "好耶😀", "好耶😭", "好耶[-😀-]{+😭+}"},
+		{"all", "abc", "xyz", "[-abc-]{+xyz+}"},
+		// 超过尺寸上限时整体替换，不再逐字比较
+		{"too large", "x" + long + "y", "z" + long + "w", "[-x" + long + "y-]{+z" + long + "w+}"},
+	} {
+		if got := Diff(c.old, c.new); got != c.want {
+			if len(got) > 100 {
+				got = got[:100] + "..."
+			}
+			t.Errorf("%s: Diff = %s, want %s", c.name, got, c.want)
+		}
+	}
+	// 未超过上限时逐字比较
+	if got := Diff("x"+long[:200]+"y", "z"+long[:200]+"w"); got != "[-x-]{+z+}"+long[:200]+"[-y-]{+w+}" {
+		t.Errorf("small Diff = %s", got)
+	}
+}
+
+func TestDiffAssets(t *testing.T) {
+	for _, c := range []struct {
+		old, new       []string
+		added, removed []string
+	}{
+		{nil, nil, nil, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}, nil, nil},
+		{[]string{"a"}, []string{"a", "b"}, []string{"b"}, nil},
+		{[]string{"a", "b"}, []string{"b"}, nil, []string{"a"}},
+		{[]string{"a", "b", "c"}, []string{"c", "d", "a"}, []string{"d"}, []string{"b"}},
+	} {
+		added, removed := DiffAssets(c.old, c.new)
+		if !slices.Equal(added, c.added) || !slices.Equal(removed, c.removed) {
+			t.Errorf("DiffAssets(%v, %v) = %v, %v, want %v, %v", c.old, c.new, added, removed, c.added, c.removed)
+		}
+	}
+}
+
+func TestEditedBlog(t *testing.T) {
+	prev := &model.Blog{ID: 7, Version: "0", Plaintext: "今天很好", Assets: []string{"a", "b"}}
+	blog := &model.Blog{Type: "blog", Version: "1", Plaintext: "今天天气很好", Assets: []string{"b", "c"}, Extra: model.Extra{"device": "iPhone"}}
+	edited := EditedBlog(prev, blog)
+	if edited.Type != "edit" || edited.Title != "编辑了微博 (第 1 版)" {
+		t.Errorf("type = %s, title = %s", edited.Type, edited.Title)
+	}
+	if want := "今天{+天气+}很好\n\n新增 1 个资源\n\n移除 1 个资源\na"; edited.Plaintext != want {
+		t.Errorf("plaintext = %q, want %q", edited.Plaintext, want)
+	}
+	if !slices.Equal(edited.Assets, []string{"c"}) {
+		t.Errorf("assets = %v", edited.Assets)
+	}
+	if edited.Extra["previous_id"] != uint64(7) || edited.Extra["previous_version"] != "0" || edited.Extra["device"] != "iPhone" {
+		t.Errorf("extra = %v", edited.Extra)
+	}
+	// 不修改原博文
+	if blog.Type != "blog" || len(blog.Assets) != 2 {
+		t.Errorf("original blog modified: %+v", blog)
+	}
+}
+
+func TestPollEdit(t *testing.T) {
+	f := newFakeWeibo(t)
+	f.JSON("/ajax/profile/info", map[string]any{"ok": 1, "data": map[string]any{"user": map[string]any{"screen_name": "me"}}})
+	mblog := func(version int, text string, pics ...string) map[string]any {
+		m := Mblog{
+			CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local).Format(time.RubyDate),
+			Mid:       "100",
+			Mblogid:   "M100",
+			User:      User{Idstr: "1"},
+			EditCount: version,
+			Text:      text,
+			TextRaw:   text,
+			PicIds:    pics,
+			PicInfos:  map[string]Pic{},
+		}
+		for _, pic := range pics {
+			m.PicInfos[pic] = Pic{Largest: PicInfo{URL: "https://wx1.sinaimg.cn/large/" + pic + ".jpg"}}
+		}
+		return map[string]any{"ok": 1, "data": map[string]any{"list": []Mblog{m}}}
+	}
+	f.JSON("/ajax/statuses/mymblog", mblog(0, "今天很好", "a", "b"))
+	m := newTestMonitor(t, f)
+	sink := make(chanSink, 2)
+	m.Routes = Routes{{Rule: Rule{Types: []string{"blog", "edit"}}, Sinks: []Sink{sink}}}
+	w := m.Watchers[0]
+	ctx := context.Background()
+	receive := func() *Message {
+		select {
+		case msg := <-sink:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+			return nil
+		}
+	}
+	w.Poll(ctx)
+	if msg := receive(); msg.Type != "blog" {
+		t.Fatalf("first notification type = %s", msg.Type)
+	}
+	// 同一版本不会重复通知
+	w.Poll(ctx)
+	f.JSON("/ajax/statuses/mymblog", mblog(1, "今天天气很好", "b", "c"))
+	w.Poll(ctx)
+	msg := receive()
+	if msg.Type != "edit" || msg.Blog.Type != "edit" {
+		t.Fatalf("notification type = %s, blog type = %s", msg.Type, msg.Blog.Type)
+	}
+	if !strings.HasPrefix(msg.Blog.Plaintext, "今天{+天气+}很好") {
+		t.Errorf("plaintext = %q", msg.Blog.Plaintext)
+	}
+	if !slices.Equal(msg.Blog.Assets, []string{"https://wx1.sinaimg.cn/large/c.jpg"}) {
+		t.Errorf("assets = %v", msg.Blog.Assets)
+	}
+	// 两个版本都会保存
+	var versions []string
+	m.DB.Model(&model.Blog{}).Where("mid = ?", "100").Order("id").Pluck("version", &versions)
+	if !slices.Equal(versions, []string{"0", "1"}) {
+		t.Errorf("versions = %v", versions)
+	}
+	select {
+	case msg := <-sink:
+		t.Errorf("unexpected notification: %s", msg.Type)
+	default:
+	}
+}
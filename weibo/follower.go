@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drelf2018/exp/hook"
+	"github.com/Drelf2018/exp/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Count 微博的数量，支持 "12.3万" 和 "1.2亿" 这样的缩写
+type Count int64
+
+// 数量单位
+var countUnits = []struct {
+	unit  string
+	value float64
+}{
+	{"亿", 1e8},
+	{"万", 1e4},
+}
+
+// ParseCount 解析微博的数量缩写，会忽略逗号、空格和结尾的加号
+func ParseCount(s string) (Count, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(strings.ReplaceAll(s, ",", "")), "+")
+	if s == "" {
+		return 0, errors.New("empty count")
+	}
+	for _, u := range countUnits {
+		if number, found := strings.CutSuffix(s, u.unit); found {
+			f, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid count %q: %w", s, err)
+			}
+			return Count(f*u.value + 0.5), nil
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid count %q: %w", s, err)
+	}
+	return Count(n), nil
+}
+
+func (c *Count) UnmarshalFlag(value string) (err error) {
+	*c, err = ParseCount(value)
+	return
+}
+
+func (c Count) MarshalFlag() (string, error) {
+	return c.String(), nil
+}
+
+// String 格式化为微博的数量缩写，保留一位小数
+func (c Count) String() string {
+	for _, u := range countUnits {
+		if float64(c) >= u.value {
+			s := strconv.FormatFloat(float64(c)/u.value, 'f', 1, 64)
+			return strings.TrimSuffix(s, ".0") + u.unit
+		}
+	}
+	return strconv.FormatInt(int64(c), 10)
+}
+
+// FollowerCount 粉丝数量记录
+type FollowerCount struct {
+	ID      uint64    `json:"id" gorm:"primaryKey;autoIncrement"`                         // 数据库内标识符
+	UID     string    `json:"uid" gorm:"index:idx_follower"`                              // 博主标识符
+	Count   Count     `json:"count"`                                                      // 粉丝数量
+	Created time.Time `json:"created" gorm:"autoCreateTime;index:idx_follower,sort:desc"` // 记录时间
+}
+
+// FollowerGrowth 获取博主最近一段时间内的粉丝增长，返回最新数量和增长数量，时间段内记录不足两条时返回假
+func FollowerGrowth(db *gorm.DB, uid string, d time.Duration) (latest, growth Count, ok bool, err error) {
+	var last, first FollowerCount
+	result := db.Where("uid = ?", uid).Order("id DESC").Limit(1).Find(&last)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, 0, false, result.Error
+	}
+	result = db.Where("uid = ? AND created >= ?", uid, last.Created.Add(-d)).Order("id ASC").Limit(1).Find(&first)
+	if result.Error != nil || result.RowsAffected == 0 || first.ID == last.ID {
+		return last.Count, 0, false, result.Error
+	}
+	return last.Count, last.Count - first.Count, true, nil
+}
+
+// 粉丝增长的统计周期
+var growthPeriods = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"日", 24 * time.Hour},
+	{"周", 7 * 24 * time.Hour},
+	{"月", 30 * 24 * time.Hour},
+}
+
+// FollowerReport 获取博主的粉丝数量和日、周、月增长
+func FollowerReport(db *gorm.DB, uid string) (string, error) {
+	var latest Count
+	var growths []string
+	for _, period := range growthPeriods {
+		l, growth, ok, err := FollowerGrowth(db, uid, period.duration)
+		if err != nil {
+			return "", err
+		}
+		latest = l
+		if ok {
+			growths = append(growths, fmt.Sprintf("%s %+d", period.name, growth))
+		}
+	}
+	if len(growths) == 0 {
+		return fmt.Sprintf("粉丝 %d", latest), nil
+	}
+	return fmt.Sprintf("粉丝 %d (%s)", latest, strings.Join(growths, " ")), nil
+}
+
+// FollowersOf 获取博主信息中的粉丝数量，优先使用精确的数量
+func FollowersOf(r ProfileInfoResponse) (Count, error) {
+	if r.Data.User.FollowersCount != 0 {
+		return Count(r.Data.User.FollowersCount), nil
+	}
+	return ParseCount(r.Data.User.FollowersCountStr)
+}
+
+// pollFollowers 轮询时从博主信息缓存中读取粉丝数量并记录，每个缓存有效期内只记录一次
+func (w *Watcher) pollFollowers(ctx context.Context) {
+	if !w.followersAt.IsZero() && time.Since(w.followersAt) < w.m.Config.ProfileTTL {
+		return
+	}
+	// 获取失败时同样等待下一个有效期，避免每次轮询都请求
+	w.followersAt = time.Now()
+	uid := strconv.Itoa(w.UID)
+	r, err := w.m.Profiles.Get(ctx, uid, w.m.Jar)
+	if err != nil {
+		w.bot.WithFields(logrus.Fields{"title": "博主信息获取失败", hook.Escalate: true}).Error(err)
+		return
+	}
+	count, err := FollowersOf(r)
+	if err != nil {
+		w.bot.WithField("title", "粉丝数量解析失败").Error(err)
+		return
+	}
+	w.recordFollowers(ctx, NewProfileSnapshot(uid, r), count)
+}
+
+// recordFollowers 记录粉丝数量，跨过里程碑时发送类型为 follower 的通知
+func (w *Watcher) recordFollowers(ctx context.Context, snap *ProfileSnapshot, count Count) {
+	var prev FollowerCount
+	result := w.m.DB.Where("uid = ?", snap.UID).Order("id DESC").Limit(1).Find(&prev)
+	if result.Error != nil {
+		w.bot.WithField("title", "粉丝数量查询失败").Error(result.Error)
+		return
+	}
+	err := w.m.DB.Create(&FollowerCount{UID: snap.UID, Count: count}).Error
+	if err != nil {
+		w.bot.WithField("title", "粉丝数量保存失败").Error(err)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	// 一次跨过多个里程碑时只通知最大的里程碑
+	var reached Count
+	for _, milestone := range w.m.Config.Milestones {
+		if prev.Count < milestone && milestone <= count && milestone > reached {
+			reached = milestone
+		}
+	}
+	if reached == 0 {
+		return
+	}
+	report, err := FollowerReport(w.m.DB, snap.UID)
+	if err != nil {
+		report = fmt.Sprintf("粉丝 %d", count)
+	}
+	blog := &model.Blog{
+		UID:       snap.UID,
+		Name:      snap.Name,
+		Avatar:    snap.Avatar,
+		Follower:  snap.Follower,
+		Following: snap.Following,
+		URL:       "https://weibo.com/u/" + snap.UID,
+		Site:      "weibo.com",
+		Type:      "follower",
+		Time:      time.Now(),
+		Title:     fmt.Sprintf("粉丝数突破 %s", reached),
+		Plaintext: report,
+		Extra:     model.Extra{"milestone": int64(reached), "count": int64(count)},
+	}
+	w.log.Infoln("粉丝里程碑:", blog)
+	w.notify(ctx, blog)
+}
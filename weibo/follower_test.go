@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseCount(t *testing.T) {
+	for s, want := range map[string]Count{
+		"123":     123,
+		"1,234":   1234,
+		"12.3万":   123000,
+		"1.2亿":    120000000,
+		" 100万+ ": 1000000,
+	} {
+		got, err := ParseCount(s)
+		if err != nil || got != want {
+			t.Errorf("ParseCount(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "万", "abc"} {
+		if _, err := ParseCount(s); err == nil {
+			t.Errorf("ParseCount(%q) should fail", s)
+		}
+	}
+	if s := Count(1234567).String(); s != "123.5万" {
+		t.Errorf("String = %s", s)
+	}
+}
+
+func TestPollRecordsFollowers(t *testing.T) {
+	f := newFakeWeibo(t)
+	var followers atomic.Int64
+	followers.Store(999999)
+	f.Handle("/ajax/profile/info", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":1,"data":{"user":{"screen_name":"me","followers_count":` + strconv.FormatInt(followers.Load(), 10) + `}}}`))
+	})
+	// 未开启博主信息快照任务时也会记录
+	m := newTestMonitor(t, f)
+	sink := make(chanSink, 1)
+	m.Routes = Routes{{Rule: Rule{Types: []string{"follower"}}, Sinks: []Sink{sink}}}
+	w := m.Watchers[0]
+	ctx := context.Background()
+	w.Poll(ctx)
+	// 缓存有效期内不会重复记录
+	w.Poll(ctx)
+	var n int64
+	m.DB.Model(&FollowerCount{}).Count(&n)
+	if n != 1 {
+		t.Fatalf("recorded %d counts, want 1", n)
+	}
+
+	followers.Store(1000001)
+	m.Profiles.Invalidate("1")
+	w.followersAt = time.Time{}
+	w.Poll(ctx)
+	m.DB.Model(&FollowerCount{}).Count(&n)
+	if n != 2 {
+		t.Fatalf("recorded %d counts, want 2", n)
+	}
+	select {
+	case msg := <-sink:
+		if msg.Type != "follower" || msg.Blog.Title != "粉丝数突破 100万" {
+			t.Errorf("unexpected message: %s %s", msg.Type, msg.Blog.Title)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("milestone was not notified")
+	}
+}
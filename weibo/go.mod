@@ -4,6 +4,7 @@ go 1.23
 
 require (
 	github.com/Drelf2018/dingtalk v0.0.0-20260119185921-eb58aad0f621
+	github.com/Drelf2018/exp/fangtang v0.0.0-00010101000000-000000000000
 	github.com/Drelf2018/exp/hook v0.0.0-20260124144020-ee0e790709c3
 	github.com/Drelf2018/exp/model v0.0.0-20260117091714-86e1c26640c1
 	github.com/Drelf2018/req v0.0.0-20260119155603-2094703bdf97
@@ -13,8 +14,8 @@ require (
 	github.com/playwright-community/playwright-go v0.5200.1
 	github.com/qiniu/go-sdk/v7 v7.25.6
 	github.com/robfig/cron/v3 v3.0.1
-	github.com/samber/slog-logrus/v2 v2.5.2
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/sync v0.9.0
 	gorm.io/gorm v1.31.1
 )
 
@@ -25,6 +26,8 @@ require (
 	github.com/alex-ant/gomath v0.0.0-20160516115720-89013a210a82 // indirect
 	github.com/andybalholm/cascadia v1.3.2 // indirect
 	github.com/antonfisher/nested-logrus-formatter v1.3.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/deckarep/golang-set/v2 v2.8.0 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/gammazero/toposort v0.1.1 // indirect
@@ -34,19 +37,23 @@ require (
 	github.com/gofrs/flock v0.8.1 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_golang v1.20.5 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-	github.com/samber/lo v1.47.0 // indirect
-	github.com/samber/slog-common v0.18.1 // indirect
 	github.com/stretchr/testify v1.11.1 // indirect
 	github.com/tidwall/gjson v1.18.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/writeas/go-strip-markdown v2.0.1+incompatible // indirect
 	golang.org/x/net v0.29.0 // indirect
-	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/fileutil v1.0.0 // indirect
 	modernc.org/libc v1.37.6 // indirect
@@ -54,3 +61,9 @@ require (
 	modernc.org/memory v1.7.2 // indirect
 	modernc.org/sqlite v1.28.0 // indirect
 )
+
+replace (
+	github.com/Drelf2018/exp/fangtang => ../fangtang
+	github.com/Drelf2018/exp/hook => ../hook
+	github.com/Drelf2018/exp/model => ../model
+)
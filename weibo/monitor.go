@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"text/template"
+
+	"github.com/Drelf2018/exp/hook"
+	"github.com/Drelf2018/exp/model"
+	"github.com/Drelf2018/req/cookie"
+	"github.com/glebarez/sqlite"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Monitor 微博监控，由 New 或 Open 创建，Run 运行直到上下文取消
+type Monitor struct {
+	Config    Options
+	Watchers  []*Watcher
+	Logger    *logrus.Logger
+	Bot       *logrus.Entry
+	Crash     *hook.CrashReporter
+	Jar       *CookieJar
+	DB        *gorm.DB
+	Profiles  *ProfileCache
+	Tmpl      *template.Template
+	Routes    Routes
+	Storage   Storage
+	Browser   Browser
+	Refresher cookie.Refresher
+
+	cron      *cron.Cron
+	servers   []*http.Server
+	ctx       context.Context // 关闭时取消，定时任务和 Run 的上下文由此派生
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	stopping  bool           // 开始关闭，不再开始轮询
+	closed    bool           // 轮询已经停止，不再开始发送
+	running   sync.WaitGroup // 正在运行的 Run
+	sends     sync.WaitGroup // 正在发送的通知
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Go 异步执行函数，关闭时会等待其完成，轮询停止后不再执行
+func (m *Monitor) Go(f func()) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		m.Logger.Warn("监控已关闭，丢弃异步任务")
+		return
+	}
+	m.sends.Add(1)
+	m.mu.Unlock()
+	m.Crash.Go(func() {
+		defer m.sends.Done()
+		f()
+	})
+}
+
+// serve 开启 HTTP 服务，关闭时会停止服务
+func (m *Monitor) serve(name, addr string, handler http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	m.servers = append(m.servers, srv)
+	go func() {
+		err := srv.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			m.Logger.Errorf("%s退出: %v", name, err)
+		}
+	}()
+}
+
+// openStorage 创建对象存储
+func (m *Monitor) openStorage() (err error) {
+	m.Storage, err = NewStorage(&m.Config.Storage, &m.Config.Qiniu)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	if local, ok := m.Storage.(*LocalStorage); ok && local.Listen != "" {
+		m.serve("静态文件服务", local.Listen, local)
+	}
+	return nil
+}
+
+// openDB 打开并迁移数据库
+func (m *Monitor) openDB() (err error) {
+	m.Logger.Info("初始化数据库")
+	m.DB, err = gorm.Open(sqlite.Open(m.Config.Database))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	err = m.DB.AutoMigrate(&model.Blog{}, &BackfillProgress{}, &ProfileSnapshot{}, &FollowerCount{}, &CachedProfile{})
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	m.Profiles = NewProfileCache(m.Config.ProfileTTL, m.DB, m.Logger)
+	return nil
+}
+
+// parseTemplate 创建博文模板
+func (m *Monitor) parseTemplate() (err error) {
+	funcMap := template.FuncMap{"media": MediaType, "prefix": hook.Prefix, "timef": hook.TimeFormat}
+	m.Tmpl, err = template.New("").Funcs(funcMap).Parse("{{if .Banner}}![]({{.Banner}})\n\n{{end}}{{template \"blog\" .}}\n\n###### {{timef .Time}}")
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	_, err = m.Tmpl.New("blog").Parse(`### {{.Name}}{{if (and .Title (ne .Type "like"))}} {{.Title}}{{end}}
+
+{{prefix .Plaintext "#### "}}{{range $idx, $asset := .Assets}}{{if eq (media $ $asset) "image" "gif" "livephoto"}}
+
+![]({{$asset}}){{end}}{{end}}{{if .Reply}}
+
+{{template "blog" .Reply}}{{end}}`)
+	if err != nil {
+		return fmt.Errorf("failed to parse blog template: %w", err)
+	}
+	return nil
+}
+
+// openRoutes 根据路由规则创建通知路由
+func (m *Monitor) openRoutes() error {
+	for i, rule := range m.Config.Rules {
+		if rule.UID != 0 && !slices.ContainsFunc(m.Config.Targets, func(t Target) bool { return t.UID == rule.UID }) {
+			return fmt.Errorf("rule of unknown target: %d", rule.UID)
+		}
+		route, err := NewRoute(i, rule, &m.Config.Logger, m.Tmpl)
+		if err != nil {
+			return fmt.Errorf("failed to create route: %w", err)
+		}
+		m.Routes = append(m.Routes, route)
+	}
+	return nil
+}
+
+// openCookie 启动浏览器或访客通行证，读取并刷新 Cookie
+func (m *Monitor) openCookie(ctx context.Context) (err error) {
+	validate := ValidateMymlog(m.Config.Validate)
+	if m.Config.Validate == 0 {
+		validate = ValidateMymlog(m.Config.Targets[0].UID)
+	}
+	if m.Config.Visitor.Enable {
+		m.Logger.Infoln("使用访客通行证:", m.Config.Visitor.Passport)
+		m.Refresher = NewVisitorRefresher(m.Config.Visitor.Passport, validate)
+	} else {
+		// 未预先设置浏览器时启动 playwright
+		if m.Browser == nil {
+			pb, err := NewPlaywrightBrowser(m.Logger)
+			if err != nil {
+				return fmt.Errorf("failed to start browser: %w", err)
+			}
+			m.Browser = pb
+		}
+		// 未配置主页时依次使用每个监控目标的主页
+		homepages := m.Config.Homepages
+		if len(homepages) == 0 {
+			for _, target := range m.Config.Targets {
+				homepages = append(homepages, target.HomepageURL())
+			}
+		}
+		m.Refresher = &BrowserRefresher{
+			Browser:   m.Browser,
+			BaseURL:   session.BaseURL,
+			Homepages: homepages,
+			Ready:     HasCookie("XSRF-TOKEN"),
+			Validate:  validate,
+			OnPage:    m.UploadScreenshot,
+			Log:       m.Logger,
+		}
+	}
+	m.Logger.Info("获取 Cookie 对象")
+	m.Jar, err = NewCookieJar(m.Config.Me, m.Config.Cookie, m.Logger, m.Bot)
+	if err != nil {
+		return fmt.Errorf("failed to load cookie: %w", err)
+	}
+	m.Logger.Info("初始化 Cookie")
+	_, err = cookie.Verify(ctx, m.Refresher, m.Jar)
+	if err != nil {
+		return fmt.Errorf("failed to refresh cookie: %w", err)
+	}
+	return nil
+}
+
+// addJobs 添加定时任务
+func (m *Monitor) addJobs() error {
+	m.cron = cron.New()
+	m.Logger.Infoln("开启 Cookie 保活:", m.Config.Crontab)
+	_, err := m.cron.AddJob(m.Config.Crontab, &cookie.KeepaliveCookieJar{
+		CookieJar: m.Jar,
+		Refresher: m.Refresher,
+		OnError:   func(err error) { m.Bot.WithField("title", "微博保活失败").Error(err) },
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add job: %w", err)
+	}
+	_, err = m.cron.AddFunc(m.Config.Status, func() {
+		for _, w := range m.Watchers {
+			report, err := FollowerReport(m.DB, strconv.Itoa(w.UID))
+			if err != nil {
+				report = err.Error()
+			}
+			w.log.Infof("监控状态: %s %s", w.Status(), report)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add job: %w", err)
+	}
+	if m.Config.Profile != "" {
+		m.Logger.Infoln("开启博主信息快照:", m.Config.Profile)
+		_, err = m.cron.AddFunc(m.Config.Profile, func() {
+			defer m.Crash.Recover()
+			for _, w := range m.Watchers {
+				w.SnapshotProfile(m.ctx)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add job: %w", err)
+		}
+	}
+	if m.Config.Comments.Crontab != "" {
+		m.Logger.Infoln("开启评论刷新:", m.Config.Comments.Crontab)
+		_, err = m.cron.AddFunc(m.Config.Comments.Crontab, func() {
+			defer m.Crash.Recover()
+			for _, w := range m.Watchers {
+				w.RefreshComments(m.ctx, m.Config.Comments)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add job: %w", err)
+		}
+	}
+	return nil
+}
+
+// New 根据配置创建微博监控，创建失败时会释放已经打开的资源
+func New(config Options) (*Monitor, error) {
+	m := &Monitor{Config: config}
+	err := m.Open()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Open 根据 Config 打开微博监控，可以预先设置 Browser 代替 playwright ，打开失败时会释放已经打开的资源
+func (m *Monitor) Open() (err error) {
+	if len(m.Config.Targets) == 0 {
+		return errors.New("no target")
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	// 初始化日志
+	m.Logger, m.Bot, err = m.Config.Logger.New()
+	if err != nil {
+		return err
+	}
+	m.Crash = hook.NewCrashReporter(m.Bot, 20)
+	defer func() {
+		if err != nil {
+			m.Close()
+		}
+	}()
+	// 创建轮询器
+	for _, target := range m.Config.Targets {
+		w, err := NewWatcher(m, target)
+		if err != nil {
+			return fmt.Errorf("failed to create watcher: %w", err)
+		}
+		m.Watchers = append(m.Watchers, w)
+	}
+	// 开启指标服务
+	if m.Config.Metrics != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", hook.MetricsHandler())
+		m.serve("指标服务", m.Config.Metrics, mux)
+	}
+	for _, open := range []func() error{
+		m.openStorage,
+		m.openDB,
+		m.parseTemplate,
+		m.openRoutes,
+		func() error { return m.openCookie(m.ctx) },
+		m.addJobs,
+	} {
+		err = open()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run 运行微博监控，上下文取消或调用 Close 后停止轮询并关闭监控。回溯模式下依次回溯每个目标后关闭
+func (m *Monitor) Run(ctx context.Context) error {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		return m.Close()
+	}
+	m.running.Add(1)
+	m.mu.Unlock()
+	m.run(ctx)
+	return m.Close()
+}
+
+// run 运行轮询或回溯直到上下文取消
+func (m *Monitor) run(ctx context.Context) {
+	defer m.running.Done()
+	defer m.Crash.Recover()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(m.ctx, cancel)
+	defer stop()
+	if m.Config.Backfill.Enable {
+		for _, w := range m.Watchers {
+			err := w.Backfill(ctx, m.Config.Backfill)
+			if err != nil {
+				w.bot.WithField("title", "回溯微博失败").Error(err)
+			}
+		}
+		return
+	}
+	m.cron.Start()
+	var wg sync.WaitGroup
+	for _, w := range m.Watchers {
+		wg.Add(1)
+		m.Crash.Go(func() {
+			defer wg.Done()
+			w.Run(ctx)
+		})
+	}
+	wg.Wait()
+	m.Logger.Info("停止监控")
+}
+
+// Close 停止轮询、定时任务和服务，等待正在发送的通知后关闭浏览器和数据库，可以重复调用
+func (m *Monitor) Close() error {
+	m.closeOnce.Do(func() {
+		var errs []error
+		// 停止轮询，等待 Run 退出后不再开始新的发送
+		if m.cancel != nil {
+			m.cancel()
+		}
+		m.mu.Lock()
+		m.stopping = true
+		m.mu.Unlock()
+		if m.cron != nil {
+			<-m.cron.Stop().Done()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.Crash.Timeout)
+		defer cancel()
+		for _, srv := range m.servers {
+			errs = append(errs, srv.Shutdown(ctx))
+		}
+		m.running.Wait()
+		m.mu.Lock()
+		m.closed = true
+		m.mu.Unlock()
+		m.sends.Wait()
+		if m.Browser != nil {
+			errs = append(errs, m.Browser.Close())
+		}
+		if m.DB != nil {
+			sqlDB, err := m.DB.DB()
+			if err == nil {
+				err = sqlDB.Close()
+			}
+			errs = append(errs, err)
+		}
+		// 等待通知钩子发送剩余的日志
+		hook.WaitHooks(m.Logger, m.Crash.Timeout)
+		m.closeErr = errors.Join(errs...)
+	})
+	return m.closeErr
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+	"github.com/jessevdk/go-flags"
+)
+
+// newTestMonitor 使用 FakeBrowser 、内存数据库和模拟微博创建监控，额外参数会追加到命令行参数后
+func newTestMonitor(t *testing.T, f *fakeWeibo, args ...string) *Monitor {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	var opts Options
+	_, err := flags.NewParser(&opts, flags.None).ParseArgs(append([]string{
+		"--target", "1,interval=10ms",
+		"--crontab", "@every 1h",
+		"--profile", "",
+		"--database", "file:" + name + "?mode=memory&cache=shared",
+		"--cookie.file", filepath.Join(t.TempDir(), "cookie.json"),
+		"--storage.type", "none",
+		"--console", "none",
+		"--escalation", "0",
+	}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	_, ok := f.handlers["/ajax/statuses/mymblog"]
+	f.mu.Unlock()
+	if !ok {
+		f.JSON("/ajax/statuses/mymblog", map[string]any{"ok": 1, "data": map[string]any{"list": []any{}}})
+	}
+	browser := &FakeBrowser{Page: Page{Cookies: []*http.Cookie{{Name: "XSRF-TOKEN", Value: "token"}}}}
+	m := &Monitor{Config: opts, Browser: browser}
+	err = m.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMonitorOpen(t *testing.T) {
+	f := newFakeWeibo(t)
+	m := newTestMonitor(t, f)
+	// 刷新 Cookie 时访问目标主页并校验
+	if visits := m.Browser.(*FakeBrowser).Visits(); len(visits) != 1 || visits[0] != "https://weibo.com/u/1" {
+		t.Errorf("visits = %v", visits)
+	}
+	if len(f.Requests("/ajax/statuses/mymblog")) == 0 {
+		t.Error("cookie was not validated")
+	}
+	if m.Jar.Store.Records()[0].Name != "XSRF-TOKEN" {
+		t.Errorf("cookie not saved: %v", m.Jar.Store.Records())
+	}
+}
+
+func TestMonitorRunCancel(t *testing.T) {
+	f := newFakeWeibo(t)
+	m := newTestMonitor(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	// 等待至少轮询一次
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Watchers[0].polls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m.Watchers[0].polls.Load() == 0 {
+		t.Fatal("watcher never polled")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after cancel")
+	}
+	sqlDB, _ := m.DB.DB()
+	if sqlDB.Ping() == nil {
+		t.Error("database is still open")
+	}
+}
+
+func TestMonitorCloseWaitsForSends(t *testing.T) {
+	f := newFakeWeibo(t)
+	m := newTestMonitor(t, f)
+	started, release := make(chan struct{}), make(chan struct{})
+	saved := make(chan error, 1)
+	m.Go(func() {
+		close(started)
+		<-release
+		// 关闭时仍能写入数据库
+		saved <- m.DB.Create(&model.Blog{MID: "1", Extra: model.Extra{}}).Error
+	})
+	<-started
+	closed := make(chan error, 1)
+	go func() { closed <- m.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("close returned before in-flight send finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-saved; err != nil {
+		t.Errorf("send could not use database: %v", err)
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("close error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close did not return")
+	}
+}
+
+func TestMonitorCloseStopsRun(t *testing.T) {
+	f := newFakeWeibo(t)
+	m := newTestMonitor(t, f)
+	done := make(chan error, 1)
+	go func() { done <- m.Run(context.Background()) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Watchers[0].polls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 不取消 Run 的上下文，直接关闭
+	if err := m.Close(); err != nil {
+		t.Errorf("close error: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after close")
+	}
+	polls := m.Watchers[0].polls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if m.Watchers[0].polls.Load() != polls {
+		t.Error("watcher still polling after close")
+	}
+}
+
+func TestMonitorGoDuringClose(t *testing.T) {
+	f := newFakeWeibo(t)
+	m := newTestMonitor(t, f)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					m.Go(func() {})
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := m.Close(); err != nil {
+		t.Errorf("close error: %v", err)
+	}
+	close(stop)
+	wg.Wait()
+	// 关闭后不再执行
+	ran := make(chan struct{}, 1)
+	m.Go(func() { ran <- struct{}{} })
+	select {
+	case <-ran:
+		t.Error("function ran after close")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
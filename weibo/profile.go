@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drelf2018/exp/model"
+)
+
+// ProfileSnapshot 博主信息快照
+type ProfileSnapshot struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`                        // 数据库内标识符
+	UID       string    `json:"uid" gorm:"index:idx_profile"`                              // 博主标识符
+	Name      string    `json:"name"`                                                      // 博主昵称
+	Desc      string    `json:"desc"`                                                      // 个人简介
+	Avatar    string    `json:"avatar"`                                                    // 头像链接
+	Banner    string    `json:"banner"`                                                    // 头图链接
+	Follower  string    `json:"follower"`                                                  // 粉丝数量
+	Following string    `json:"following"`                                                 // 关注数量
+	Created   time.Time `json:"created" gorm:"autoCreateTime;index:idx_profile,sort:desc"` // 快照时间
+}
+
+// NewProfileSnapshot 根据博主信息创建快照
+func NewProfileSnapshot(uid string, r ProfileInfoResponse) *ProfileSnapshot {
+	user := r.Data.User
+	banner, _, _ := strings.Cut(user.CoverImagePhone, ";")
+	return &ProfileSnapshot{
+		UID:       uid,
+		Name:      user.ScreenName,
+		Desc:      user.Description,
+		Avatar:    user.AvatarHd,
+		Banner:    banner,
+		Follower:  user.FollowersCountStr,
+		Following: strconv.Itoa(user.FriendsCount),
+	}
+}
+
+// ProfileChange 博主信息的一处变化
+type ProfileChange struct {
+	Field  string `json:"field"`  // 字段名称
+	Before string `json:"before"` // 修改前的值
+	After  string `json:"after"`  // 修改后的值
+}
+
+// sameImage 判断两个图片链接是否指向同一张图片，微博图片链接的域名和签名参数会变化
+func sameImage(a, b string) bool {
+	ua, err1 := url.Parse(a)
+	ub, err2 := url.Parse(b)
+	if err1 != nil || err2 != nil {
+		return a == b
+	}
+	return ua.Path == ub.Path
+}
+
+// Changes 比较快照中的昵称、简介、头像和头图
+func (s *ProfileSnapshot) Changes(next *ProfileSnapshot) (changes []ProfileChange) {
+	if s.Name != next.Name {
+		changes = append(changes, ProfileChange{"昵称", s.Name, next.Name})
+	}
+	if s.Desc != next.Desc {
+		changes = append(changes, ProfileChange{"简介", s.Desc, next.Desc})
+	}
+	if !sameImage(s.Avatar, next.Avatar) {
+		changes = append(changes, ProfileChange{"头像", s.Avatar, next.Avatar})
+	}
+	if !sameImage(s.Banner, next.Banner) {
+		changes = append(changes, ProfileChange{"头图", s.Banner, next.Banner})
+	}
+	return
+}
+
+// ProfileBlog 生成类型为 profile 的通知博文，正文为修改前后的值，资源为修改后的图片
+func ProfileBlog(snap *ProfileSnapshot, changes []ProfileChange) *model.Blog {
+	blog := &model.Blog{
+		UID:       snap.UID,
+		Name:      snap.Name,
+		Desc:      snap.Desc,
+		Avatar:    snap.Avatar,
+		Banner:    snap.Banner,
+		Follower:  snap.Follower,
+		Following: snap.Following,
+		URL:       "https://weibo.com/u/" + snap.UID,
+		Site:      "weibo.com",
+		Type:      "profile",
+		Time:      time.Now(),
+		Title:     "修改了个人资料",
+		Extra:     model.Extra{"changes": changes},
+	}
+	media := make(map[string]string)
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		switch change.Field {
+		case "头像", "头图":
+			lines = append(lines, fmt.Sprintf("%s: 已更换", change.Field))
+			if change.After != "" {
+				blog.Assets = append(blog.Assets, change.After)
+				media[change.After] = MediaImage
+			}
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %s → %s", change.Field, change.Before, change.After))
+		}
+	}
+	blog.Plaintext = strings.Join(lines, "\n")
+	blog.Extra["media"] = media
+	return blog
+}
+
+// SnapshotProfile 保存目标的博主信息快照，昵称、简介、头像或头图变化时发送通知
+func (w *Watcher) SnapshotProfile(ctx context.Context) {
+	uid := strconv.Itoa(w.UID)
+	// 快照需要最新的信息，不使用缓存
+	r, err := GetProfileInfo(ctx, uid, w.m.Jar)
+	if err != nil {
+		w.bot.WithField("title", "博主信息获取失败").Error(err)
+		return
+	}
+	snap := NewProfileSnapshot(uid, r)
+	var prev ProfileSnapshot
+	result := w.m.DB.Where("uid = ?", uid).Order("id DESC").Limit(1).Find(&prev)
+	if result.Error != nil {
+		w.bot.WithField("title", "博主信息查询失败").Error(result.Error)
+		return
+	}
+	err = w.m.DB.Create(snap).Error
+	if err != nil {
+		w.bot.WithField("title", "博主信息保存失败").Error(err)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	changes := prev.Changes(snap)
+	if len(changes) == 0 {
+		return
+	}
+	// 资料已经变化，清除过时的缓存
+	w.m.Profiles.Invalidate(uid)
+	blog := ProfileBlog(snap, changes)
+	w.log.Infoln("修改资料:", blog)
+	w.notify(ctx, blog)
+}
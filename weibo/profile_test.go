@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestProfileChanges(t *testing.T) {
+	before := &ProfileSnapshot{
+		Name:     "小明",
+		Desc:     "简介",
+		Avatar:   "https://tvax1.sinaimg.cn/crop.0.0.1080.1080.1024/avatar.jpg?KID=imgbed,tva&Expires=1&ssig=a",
+		Banner:   "https://wx1.sinaimg.cn/crop.0.0.640.640.640/banner.jpg",
+		Follower: "100",
+	}
+	for _, c := range []struct {
+		name string
+		edit func(s *ProfileSnapshot)
+		want []ProfileChange
+	}{
+		{"unchanged", func(s *ProfileSnapshot) {}, nil},
+		// 粉丝数量不算资料修改
+		{"follower", func(s *ProfileSnapshot) { s.Follower = "101" }, nil},
+		// 域名和签名参数变化时仍是同一张图片
+		{"resigned", func(s *ProfileSnapshot) {
+			s.Avatar = "https://tvax2.sinaimg.cn/crop.0.0.1080.1080.1024/avatar.jpg?KID=imgbed,tva&Expires=2&ssig=b"
+		}, nil},
+		{"name", func(s *ProfileSnapshot) { s.Name = "小红" }, []ProfileChange{{"昵称", "小明", "小红"}}},
+		{"desc and avatar", func(s *ProfileSnapshot) {
+			s.Desc = ""
+			s.Avatar = "https://tvax1.sinaimg.cn/crop.0.0.1080.1080.1024/new.jpg"
+		}, []ProfileChange{
+			{"简介", "简介", ""},
+			{"头像", before.Avatar, "https://tvax1.sinaimg.cn/crop.0.0.1080.1080.1024/new.jpg"},
+		}},
+		{"banner", func(s *ProfileSnapshot) { s.Banner = "" }, []ProfileChange{{"头图", before.Banner, ""}}},
+	} {
+		after := *before
+		c.edit(&after)
+		if got := before.Changes(&after); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: Changes = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestProfileBlog(t *testing.T) {
+	snap := &ProfileSnapshot{UID: "1", Name: "小红", Avatar: "https://wx1.sinaimg.cn/new.jpg"}
+	blog := ProfileBlog(snap, []ProfileChange{
+		{"昵称", "小明", "小红"},
+		{"头像", "https://wx1.sinaimg.cn/old.jpg", "https://wx1.sinaimg.cn/new.jpg"},
+		{"头图", "https://wx1.sinaimg.cn/banner.jpg", ""},
+	})
+	if want := "昵称: 小明 → 小红\n头像: 已更换\n头图: 已更换"; blog.Plaintext != want {
+		t.Errorf("plaintext = %q, want %q", blog.Plaintext, want)
+	}
+	// 只保留修改后的图片
+	if !slices.Equal(blog.Assets, []string{"https://wx1.sinaimg.cn/new.jpg"}) {
+		t.Errorf("assets = %v", blog.Assets)
+	}
+	if blog.Type != "profile" || blog.URL != "https://weibo.com/u/1" {
+		t.Errorf("type = %s, url = %s", blog.Type, blog.URL)
+	}
+	if got := MediaType(blog, "https://wx1.sinaimg.cn/new.jpg"); got != MediaImage {
+		t.Errorf("media type = %s", got)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Drelf2018/dingtalk"
+	"github.com/Drelf2018/exp/fangtang"
+	"github.com/Drelf2018/exp/hook"
+	"github.com/Drelf2018/exp/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Message 发送给推送目标的通知
+type Message struct {
+	Target int         // 监控目标
+	Type   string      // 博文类型
+	Title  string      // 标题
+	Text   string      // 模板渲染后的 Markdown 正文，渲染失败时为空
+	Blog   *model.Blog // 原始博文，点赞时为包装后的博文
+}
+
+// Content 获取正文，模板渲染失败时使用纯文本
+func (m *Message) Content() string {
+	if m.Text != "" {
+		return m.Text
+	}
+	return m.Blog.Plaintext
+}
+
+// Entry 将通知转换成日志事件，字段与钩子发送通知时相同，可以直接使用日志机器人的模板
+func (m *Message) Entry() *logrus.Entry {
+	return &logrus.Entry{
+		Level:   logrus.InfoLevel,
+		Time:    m.Blog.Time,
+		Message: m.Content(),
+		Data: logrus.Fields{
+			"header": m.Title,
+			"title":  m.Title,
+			"url":    m.Blog.URL,
+			"button": "阅读全文",
+			"target": m.Target,
+			"type":   m.Type,
+		},
+	}
+}
+
+// Sink 通知的推送目标
+type Sink interface {
+	Send(ctx context.Context, msg *Message) error
+	String() string
+}
+
+// DingTalkSink 钉钉机器人，发送卡片，失败时退避为发送链接
+type DingTalkSink struct {
+	Bot *dingtalk.Bot
+}
+
+// sendCard 发送卡片，重试三次，如果一直系统繁忙则返回错误
+func (d DingTalkSink) sendCard(msg *Message) (err error) {
+	card := &dingtalk.ActionCard{Title: " " + msg.Title, Text: msg.Text, SingleTitle: "阅读全文", SingleURL: msg.Blog.URL}
+	msgUUID := dingtalk.UUID(uuid.NewString())
+	for i := range 3 {
+		if i != 0 {
+			time.Sleep((1 << i) * time.Second)
+		}
+		err = d.Bot.Send(card, msgUUID)
+		if err == nil {
+			return nil
+		}
+		// 服务器系统繁忙，等待后重试
+		if respErr, ok := err.(dingtalk.SendError); ok && respErr.ErrCode == -1 {
+			continue
+		}
+		break
+	}
+	return err
+}
+
+func (d DingTalkSink) Send(ctx context.Context, msg *Message) error {
+	var cardErr error
+	if msg.Text != "" {
+		cardErr = d.sendCard(msg)
+		if cardErr == nil {
+			return nil
+		}
+	}
+	err := d.Bot.SendLinkWithContext(ctx, msg.Blog.Name, msg.Blog.Plaintext, msg.Blog.URL, msg.Blog.Avatar)
+	if cardErr != nil {
+		err = fmt.Errorf("failed to send card: %w, then failed to send link: %w", unwrapURL(cardErr), unwrapURL(err))
+	}
+	return unwrapURL(err)
+}
+
+func (d DingTalkSink) String() string {
+	return "dingtalk:" + d.Bot.Name
+}
+
+// unwrapURL 去掉请求错误中的链接，避免泄露令牌
+func unwrapURL(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Unwrap()
+	}
+	return err
+}
+
+// FangTangSink 方糖推送
+type FangTangSink struct {
+	Name string // 名称，不包含发送密钥
+	Key  fangtang.FangTang
+}
+
+// 方糖消息标题的最大长度
+const fangtangTitleMax = 32
+
+func (f FangTangSink) Send(ctx context.Context, msg *Message) error {
+	title := []rune(msg.Title)
+	if len(title) > fangtangTitleMax {
+		title = append(title[:fangtangTitleMax-1], '…')
+	}
+	desp := msg.Content()
+	if msg.Blog.URL != "" {
+		desp += "\n\n[阅读全文](" + msg.Blog.URL + ")"
+	}
+	_, err := f.Key.SendWithContext(ctx, string(title), desp)
+	return unwrapURL(err)
+}
+
+func (f FangTangSink) String() string {
+	return "fangtang:" + f.Name
+}
+
+// WebhookMessage 通用 Webhook 的请求体
+type WebhookMessage struct {
+	Target int         `json:"target"`
+	Type   string      `json:"type"`
+	Title  string      `json:"title"`
+	Text   string      `json:"text"`
+	URL    string      `json:"url"`
+	Blog   *model.Blog `json:"blog"`
+}
+
+// WebhookSink 通用 Webhook 。机器人带有请求体模板时，例如飞书和企业微信，使用模板渲染通知的日志事件，否则以 JSON 格式推送 WebhookMessage
+type WebhookSink struct {
+	Bot *hook.WebhookBot
+}
+
+func (w WebhookSink) Send(ctx context.Context, msg *Message) error {
+	if w.Bot.Template != nil {
+		return unwrapURL(w.Bot.SendWithContext(ctx, msg.Entry()))
+	}
+	body, err := json.Marshal(WebhookMessage{
+		Target: msg.Target,
+		Type:   msg.Type,
+		Title:  msg.Title,
+		Text:   msg.Content(),
+		URL:    msg.Blog.URL,
+		Blog:   msg.Blog,
+	})
+	if err != nil {
+		return err
+	}
+	return unwrapURL(w.Bot.Post(ctx, body))
+}
+
+func (w WebhookSink) String() string {
+	return "webhook:" + w.Bot.Name
+}
+
+// Rule 通知路由规则，格式为 "uid|*[,types=blog+like][,dingtalk=name|token[:secret]][,fangtang=key][,webhook=name|url|lark:token[:secret]|wecom:key][,template=file]"
+//
+// 推送目标可以重复填写，钉钉机器人的值与日志配置中的机器人名称相同时使用该机器人，否则视为新机器人的令牌。
+// Webhook 的值与日志配置中飞书或企业微信机器人的名称相同时使用该机器人，带有 lark: 或 wecom: 前缀时使用对应的预设创建新机器人，否则视为通用 Webhook 的推送地址
+type Rule struct {
+	// 目标 UID ，为零时匹配全部目标
+	UID int
+
+	// 博文类型，例如 blog 、 like 、 edit 和 delete ，为空时匹配全部类型
+	Types []string
+
+	// 钉钉机器人
+	DingTalk []string
+
+	// 方糖发送密钥
+	FangTang []string
+
+	// 飞书、企业微信或通用 Webhook
+	Webhook []string
+
+	// 通知模板文件，可以使用子模板 "blog" ，为空时使用默认模板
+	Template string
+}
+
+func (r *Rule) UnmarshalFlag(value string) error {
+	fields := strings.Split(value, ",")
+	*r = Rule{}
+	if uid := strings.TrimSpace(fields[0]); uid != "*" {
+		var err error
+		r.UID, err = strconv.Atoi(uid)
+		if err != nil {
+			return fmt.Errorf("invalid rule uid: %s", fields[0])
+		}
+	}
+	for _, field := range fields[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "types":
+			if val != "*" {
+				r.Types = strings.Split(val, "+")
+			}
+		case "dingtalk":
+			r.DingTalk = append(r.DingTalk, val)
+		case "fangtang":
+			r.FangTang = append(r.FangTang, val)
+		case "webhook":
+			r.Webhook = append(r.Webhook, val)
+		case "template":
+			r.Template = val
+		default:
+			return fmt.Errorf("unknown rule option: %s", key)
+		}
+	}
+	if len(r.DingTalk)+len(r.FangTang)+len(r.Webhook) == 0 {
+		return fmt.Errorf("rule without sink: %s", value)
+	}
+	return nil
+}
+
+func (r Rule) MarshalFlag() (string, error) {
+	s := "*"
+	if r.UID != 0 {
+		s = strconv.Itoa(r.UID)
+	}
+	if len(r.Types) != 0 {
+		s += ",types=" + strings.Join(r.Types, "+")
+	}
+	for _, v := range r.DingTalk {
+		s += ",dingtalk=" + v
+	}
+	for _, v := range r.FangTang {
+		s += ",fangtang=" + v
+	}
+	for _, v := range r.Webhook {
+		s += ",webhook=" + v
+	}
+	if r.Template != "" {
+		s += ",template=" + r.Template
+	}
+	return s, nil
+}
+
+// Match 判断规则是否匹配目标和博文类型
+func (r Rule) Match(uid int, typ string) bool {
+	return (r.UID == 0 || r.UID == uid) && (len(r.Types) == 0 || slices.Contains(r.Types, typ))
+}
+
+// Route 编译后的通知路由
+type Route struct {
+	Rule
+	Sinks []Sink
+	Tmpl  *template.Template // 通知模板，为空时使用默认模板
+}
+
+// 路由中新建的 Webhook 机器人的请求超时时间
+const routeWebhookTimeout = 10 * time.Second
+
+// newWebhookBot 根据规则中的值创建 Webhook 机器人，名称与日志配置中的飞书或企业微信机器人相同时使用该机器人
+func newWebhookBot(name, v string, config *hook.Config) (*hook.WebhookBot, error) {
+	for _, bot := range []*hook.WebhookBot{config.Lark, config.WeCom} {
+		if bot != nil && bot.URL != "" && v == bot.Name {
+			return bot, nil
+		}
+	}
+	var bot *hook.WebhookBot
+	kind, rest, _ := strings.Cut(v, ":")
+	switch {
+	case kind == "lark" && rest != "":
+		token, secret, _ := strings.Cut(rest, ":")
+		bot = hook.NewLarkBot(name, token, secret)
+	case kind == "wecom" && rest != "":
+		bot = hook.NewWeComBot(name, rest)
+	default:
+		u, err := url.Parse(v)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("unknown webhook: %s", v)
+		}
+		bot = &hook.WebhookBot{Name: name, URL: v}
+	}
+	bot.Timeout = routeWebhookTimeout
+	return bot, nil
+}
+
+// NewRoute 根据第 index 条规则创建推送目标并解析模板，模板基于默认模板克隆。
+// 新建的机器人以规则序号命名，例如 rule1.2 表示第一条规则中该类推送目标的第二个，避免在日志中泄露令牌
+func NewRoute(index int, rule Rule, config *hook.Config, base *template.Template) (*Route, error) {
+	route := &Route{Rule: rule}
+	name := func(i int) string {
+		return fmt.Sprintf("rule%d.%d", index+1, i+1)
+	}
+	for i, v := range rule.DingTalk {
+		if config.DingTalk != nil && config.DingTalk.Token != "" && v == config.DingTalk.Name {
+			route.Sinks = append(route.Sinks, DingTalkSink{Bot: config.DingTalk})
+			continue
+		}
+		token, secret, _ := strings.Cut(v, ":")
+		if token == "" {
+			return nil, fmt.Errorf("unknown dingtalk bot: %s", v)
+		}
+		route.Sinks = append(route.Sinks, DingTalkSink{Bot: &dingtalk.Bot{Name: name(i), Token: token, Secret: secret}})
+	}
+	for i, v := range rule.FangTang {
+		route.Sinks = append(route.Sinks, FangTangSink{Name: name(i), Key: fangtang.FangTang(v)})
+	}
+	for i, v := range rule.Webhook {
+		bot, err := newWebhookBot(name(i), v, config)
+		if err != nil {
+			return nil, err
+		}
+		route.Sinks = append(route.Sinks, WebhookSink{Bot: bot})
+	}
+	if rule.Template != "" {
+		b, err := os.ReadFile(rule.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		route.Tmpl, err = base.Clone()
+		if err == nil {
+			_, err = route.Tmpl.Parse(string(b))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", rule.Template, err)
+		}
+	}
+	return route, nil
+}
+
+// Routes 按顺序匹配的通知路由
+type Routes []*Route
+
+// Match 获取第一个匹配目标和博文类型的路由，不存在时返回空
+func (rs Routes) Match(uid int, typ string) *Route {
+	for _, r := range rs {
+		if r.Match(uid, typ) {
+			return r
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drelf2018/dingtalk"
+	"github.com/Drelf2018/exp/hook"
+	"github.com/Drelf2018/exp/model"
+)
+
+func TestRuleFlag(t *testing.T) {
+	var r Rule
+	err := r.UnmarshalFlag("1,types=blog+like,dingtalk=bot,fangtang=key,webhook=lark:token:secret,template=a.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.UID != 1 || len(r.Types) != 2 || r.Webhook[0] != "lark:token:secret" || r.Template != "a.tmpl" {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+	if !r.Match(1, "like") || r.Match(1, "delete") || r.Match(2, "blog") {
+		t.Error("unexpected match")
+	}
+	s, _ := r.MarshalFlag()
+	if s != "1,types=blog+like,dingtalk=bot,fangtang=key,webhook=lark:token:secret,template=a.tmpl" {
+		t.Errorf("marshal = %s", s)
+	}
+	for _, v := range []string{"*", "x,dingtalk=bot", "*,unknown=1"} {
+		if err := new(Rule).UnmarshalFlag(v); err == nil {
+			t.Errorf("UnmarshalFlag(%q) should fail", v)
+		}
+	}
+}
+
+func TestNewRoute(t *testing.T) {
+	config := &hook.Config{
+		DingTalk: &dingtalk.Bot{Name: "bot", Token: "configured"},
+		Lark:     hook.NewLarkBot("lark", "configured", ""),
+	}
+	rule := Rule{
+		DingTalk: []string{"bot", "dtoken:dsecret"},
+		FangTang: []string{"SCTsendkey"},
+		Webhook:  []string{"lark", "lark:ltoken:lsecret", "wecom:wkey", "https://example.com/hook?key=hkey"},
+	}
+	route, err := NewRoute(1, rule, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sink := range route.Sinks {
+		names = append(names, sink.String())
+	}
+	want := "dingtalk:bot dingtalk:rule2.2 fangtang:rule2.1 webhook:lark webhook:rule2.2 webhook:rule2.3 webhook:rule2.4"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("sinks = %s, want %s", got, want)
+	}
+	// 名称中不包含令牌
+	for _, secret := range []string{"dtoken", "SCT", "ltoken", "wkey", "hkey"} {
+		if strings.Contains(strings.Join(names, " "), secret) {
+			t.Errorf("sink names leak %s", secret)
+		}
+	}
+	if bot := route.Sinks[3].(WebhookSink).Bot; bot != config.Lark {
+		t.Error("configured lark bot is not reused")
+	}
+	if bot := route.Sinks[4].(WebhookSink).Bot; bot.URL != hook.LarkURL+"ltoken" || bot.Secret != "lsecret" || bot.Template == nil {
+		t.Errorf("unexpected lark bot: %+v", bot)
+	}
+	if bot := route.Sinks[5].(WebhookSink).Bot; bot.URL != hook.WeComURL+"wkey" || bot.Template == nil {
+		t.Errorf("unexpected wecom bot: %+v", bot)
+	}
+	if bot := route.Sinks[6].(WebhookSink).Bot; bot.Template != nil || bot.Timeout != routeWebhookTimeout {
+		t.Errorf("unexpected generic webhook: %+v", bot)
+	}
+	for _, v := range []string{"unknown", "lark:", "wecom:"} {
+		if _, err := NewRoute(0, Rule{Webhook: []string{v}}, config, nil); err == nil {
+			t.Errorf("webhook %q should fail", v)
+		}
+	}
+}
+
+func TestRoutesMatch(t *testing.T) {
+	rs := Routes{
+		{Rule: Rule{UID: 1, Types: []string{"delete"}}},
+		{Rule: Rule{UID: 1}},
+		{Rule: Rule{}},
+	}
+	if rs.Match(1, "delete") != rs[0] || rs.Match(1, "blog") != rs[1] || rs.Match(2, "delete") != rs[2] {
+		t.Error("routes should match in order")
+	}
+	if (Routes{}).Match(1, "blog") != nil {
+		t.Error("empty routes should not match")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+		w.Write([]byte(`{"code":0,"errcode":0}`))
+	}))
+	defer srv.Close()
+	msg := &Message{
+		Target: 1,
+		Type:   "blog",
+		Title:  "me 发布了微博",
+		Text:   "### me\n\n#### 你好",
+		Blog:   &model.Blog{URL: "https://weibo.com/1/A", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)},
+	}
+	send := func(bot *hook.WebhookBot) map[string]any {
+		t.Helper()
+		bot.URL = srv.URL
+		if err := (WebhookSink{Bot: bot}).Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]any
+		if err := json.Unmarshal(<-bodies, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// 飞书使用预设模板，带有签名
+	v := send(hook.NewLarkBot("lark", "", "secret"))
+	text, _ := v["content"].(map[string]any)["text"].(string)
+	if v["msg_type"] != "text" || v["sign"] == nil || text != "me 发布了微博\nme\n\n你好\n2024-01-02 03:04:05" {
+		t.Errorf("unexpected lark body: %v", v)
+	}
+	// 企业微信使用 markdown 模板
+	v = send(hook.NewWeComBot("wecom", ""))
+	content, _ := v["markdown"].(map[string]any)["content"].(string)
+	if v["msgtype"] != "markdown" || !strings.HasPrefix(content, "### me 发布了微博\n\n> ### me") {
+		t.Errorf("unexpected wecom body: %v", v)
+	}
+	// 通用 Webhook 推送 JSON
+	v = send(&hook.WebhookBot{Name: "generic"})
+	if v["target"] != float64(1) || v["type"] != "blog" || v["title"] != msg.Title || v["text"] != msg.Text || v["url"] != msg.Blog.URL {
+		t.Errorf("unexpected generic body: %v", v)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/storagev2/credentials"
+	"github.com/qiniu/go-sdk/v7/storagev2/http_client"
+	"github.com/qiniu/go-sdk/v7/storagev2/objects"
+	"github.com/qiniu/go-sdk/v7/storagev2/uploader"
+)
+
+// Storage 对象存储
+type Storage interface {
+	// Put 上传对象，expiry 大于零时对象会在该时间后过期
+	Put(ctx context.Context, name string, data []byte, contentType string, expiry time.Duration) error
+
+	// URL 获取对象的公开链接
+	URL(name string) string
+}
+
+// StorageOptions 对象存储配置
+type StorageOptions struct {
+	Type   string        `long:"type" default:"qiniu" choice:"qiniu" choice:"s3" choice:"local" choice:"none" description:"对象存储类型，为 none 时不上传截图"`
+	Expiry time.Duration `long:"expiry" default:"72h" description:"截图有效期"`
+	Local  LocalStorage  `group:"Local" namespace:"local" description:"本地文件存储"`
+	S3     S3Storage     `group:"S3" namespace:"s3" description:"S3 兼容存储"`
+}
+
+// 本地存储中记录过期时间的文件后缀
+const expiresSuffix = ".expires"
+
+// LocalStorage 本地文件存储，可以开启静态文件服务提供公开链接
+type LocalStorage struct {
+	Dir    string `long:"dir" default:"screenshots" description:"存储目录"`
+	Listen string `long:"listen" description:"静态文件服务监听地址，为空时不启用"`
+	Public string `long:"public" description:"公开链接前缀，为空时使用 http://<listen>"`
+}
+
+// path 获取对象的本地路径，对象名称不能跳出存储目录
+func (l *LocalStorage) path(name string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(name)), nil
+}
+
+// expired 判断对象是否已经过期
+func (l *LocalStorage) expired(p string, now time.Time) bool {
+	b, err := os.ReadFile(p + expiresSuffix)
+	if err != nil {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+	return err == nil && !t.After(now)
+}
+
+// Clean 删除已经过期的对象
+func (l *LocalStorage) Clean() error {
+	now := time.Now()
+	return filepath.WalkDir(l.Dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(p, expiresSuffix) || !l.expired(p, now) {
+			return err
+		}
+		return errors.Join(os.Remove(p), os.Remove(p+expiresSuffix))
+	})
+}
+
+func (l *LocalStorage) Put(ctx context.Context, name string, data []byte, contentType string, expiry time.Duration) error {
+	p, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	err = WriteFileAtomic(p, data, 0644)
+	if err != nil {
+		return err
+	}
+	if expiry > 0 {
+		deadline := time.Now().Add(expiry).Format(time.RFC3339)
+		err = WriteFileAtomic(p+expiresSuffix, []byte(deadline), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	// 顺便清理过期的对象
+	return l.Clean()
+}
+
+func (l *LocalStorage) URL(name string) string {
+	public := l.Public
+	if public == "" {
+		public = "http://" + l.Listen
+	}
+	return joinURL(public, name)
+}
+
+// ServeHTTP 提供未过期对象的静态文件服务，不会列出目录
+func (l *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	p, err := l.path(name)
+	if err != nil || strings.HasSuffix(name, expiresSuffix) || l.expired(p, time.Now()) {
+		http.NotFound(w, r)
+		return
+	}
+	info, err := os.Stat(p)
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, p)
+}
+
+var _ Storage = (*LocalStorage)(nil)
+
+// S3Storage S3 兼容存储，使用 AWS Signature Version 4 签名。
+// S3 不支持为单个对象设置过期时间，上传时会添加 expiry-days 标签，需要在存储桶中配置对应的生命周期规则
+type S3Storage struct {
+	Endpoint  string       `long:"endpoint" description:"服务地址，例如 https://s3.us-east-1.amazonaws.com"`
+	Region    string       `long:"region" default:"us-east-1" description:"区域"`
+	Bucket    string       `long:"bucket" description:"存储桶"`
+	AccessKey string       `long:"access-key" description:"AccessKey"`
+	SecretKey string       `long:"secret-key" description:"SecretKey"`
+	PathStyle bool         `long:"path-style" description:"使用路径形式的链接，MinIO 等服务需要开启"`
+	Public    string       `long:"public" description:"公开链接前缀，为空时使用对象链接"`
+	Client    *http.Client `no-flag:"true"`
+}
+
+// s3Escape 按照 S3 的规则编码，只保留非保留字符
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || (slash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// joinURL 拼接公开链接前缀和对象名称，对象名称按照 S3 的规则编码，各种存储的链接保持一致
+func joinURL(prefix, name string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + s3Escape(name, true)
+}
+
+// objectURL 获取对象的链接
+func (s *S3Storage) objectURL(name string) (*url.URL, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.PathStyle {
+		u.Path = "/" + s.Bucket + "/" + name
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = "/" + name
+	}
+	u.RawPath = s3Escape(u.Path, true)
+	return u, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Sign 使用 AWS Signature Version 4 为请求签名，会签名请求中的所有请求头
+func (s *S3Storage) Sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	// 规范请求头
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	// 规范查询参数
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	// 待签名字符串
+	date := amzDate[:8]
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	// 派生签名密钥
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func (s *S3Storage) Put(ctx context.Context, name string, data []byte, contentType string, expiry time.Duration) error {
+	u, err := s.objectURL(name)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if expiry > 0 {
+		days := int64((expiry + 24*time.Hour - 1) / (24 * time.Hour))
+		req.Header.Set("X-Amz-Tagging", fmt.Sprintf("expiry-days=%d", days))
+		req.Header.Set("Expires", time.Now().Add(expiry).UTC().Format(http.TimeFormat))
+	}
+	sum := sha256.Sum256(data)
+	s.Sign(req, hex.EncodeToString(sum[:]), time.Now())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to put object: %s (%s)", body, resp.Status)
+	}
+	return nil
+}
+
+func (s *S3Storage) URL(name string) string {
+	if s.Public != "" {
+		return joinURL(s.Public, name)
+	}
+	u, err := s.objectURL(name)
+	if err != nil {
+		return name
+	}
+	return u.String()
+}
+
+var _ Storage = (*S3Storage)(nil)
+
+// Qiniu 七牛云存储
+type Qiniu struct {
+	AccessKey string `long:"accessKey" description:"七牛云 AccessKey"`
+	SecretKey string `long:"secretKey" description:"七牛云 SecretKey"`
+	Bucket    string `long:"bucket" default:"apex12138" description:"七牛云存储桶"`
+	Domain    string `long:"domain" default:"https://yun.nana7mi.link" description:"七牛云存储桶绑定的域名"`
+
+	uploader *uploader.UploadManager
+	bucket   *objects.Bucket
+}
+
+// Open 创建上传和对象管理器
+func (q *Qiniu) Open() {
+	mac := credentials.NewCredentials(q.AccessKey, q.SecretKey)
+	q.uploader = uploader.NewUploadManager(&uploader.UploadManagerOptions{
+		Options: http_client.Options{Credentials: mac},
+	})
+	objectsManager := objects.NewObjectsManager(&objects.ObjectsManagerOptions{
+		Options: http_client.Options{Credentials: mac},
+	})
+	q.bucket = objectsManager.Bucket(q.Bucket)
+}
+
+func (q *Qiniu) Put(ctx context.Context, name string, data []byte, contentType string, expiry time.Duration) error {
+	err := q.uploader.UploadReader(ctx, bytes.NewReader(data), &uploader.ObjectOptions{
+		BucketName:  q.bucket.Name(),
+		ObjectName:  &name,
+		FileName:    name,
+		ContentType: contentType,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if expiry <= 0 {
+		return nil
+	}
+	// 七牛云的生命周期以天为单位
+	days := int64((expiry + 24*time.Hour - 1) / (24 * time.Hour))
+	err = q.bucket.Object(name).SetLifeCycle().DeleteAfterDays(days).Call(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to set lifecycle: %w", err)
+	}
+	return nil
+}
+
+func (q *Qiniu) URL(name string) string {
+	return joinURL(q.Domain, name)
+}
+
+var _ Storage = (*Qiniu)(nil)
+
+// NewStorage 根据配置创建对象存储，类型为 none 时返回空
+func NewStorage(opts *StorageOptions, q *Qiniu) (Storage, error) {
+	switch opts.Type {
+	case "qiniu":
+		q.Open()
+		return q, nil
+	case "s3":
+		if opts.S3.Endpoint == "" || opts.S3.Bucket == "" {
+			return nil, errors.New("s3 endpoint and bucket are required")
+		}
+		return &opts.S3, nil
+	case "local":
+		err := os.MkdirAll(opts.Local.Dir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		return &opts.Local, nil
+	case "none", "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", opts.Type)
+	}
+}
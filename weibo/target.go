@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Drelf2018/exp/hook"
+	"github.com/Drelf2018/exp/model"
+	"github.com/Drelf2018/req"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Target 监控目标，格式为 "uid[,interval=7s-10s][,route=name][,homepage=url]"
+type Target struct {
+	// 目标 UID
+	UID int
+
+	// 轮询间隔范围
+	Interval Interval
+
+	// 通知路由，即接收通知的机器人名称，为空时使用默认机器人
+	Route string
+
+	// 刷新 Cookie 时访问的微博主页，为空时使用目标主页
+	Homepage string
+}
+
+// Interval 随机间隔范围，格式为 "7s-10s" 或 "7s"
+type Interval req.RandomTicker
+
+func (i *Interval) UnmarshalFlag(value string) error {
+	min, max, found := strings.Cut(value, "-")
+	var err error
+	i[0], err = time.ParseDuration(min)
+	if err != nil {
+		return err
+	}
+	i[1] = i[0]
+	if found {
+		i[1], err = time.ParseDuration(max)
+	}
+	return err
+}
+
+func (i Interval) MarshalFlag() (string, error) {
+	if i[0] == i[1] {
+		return i[0].String(), nil
+	}
+	return i[0].String() + "-" + i[1].String(), nil
+}
+
+func (i Interval) String() string {
+	s, _ := i.MarshalFlag()
+	return s
+}
+
+// Next 获取下一次随机间隔
+func (i Interval) Next() time.Duration {
+	delay, _ := req.RandomTicker(i).NextRetry(0)
+	return delay
+}
+
+func (t *Target) UnmarshalFlag(value string) error {
+	fields := strings.Split(value, ",")
+	uid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return fmt.Errorf("invalid target uid: %s", fields[0])
+	}
+	*t = Target{UID: uid, Interval: Interval{7 * time.Second, 10 * time.Second}}
+	for _, field := range fields[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "interval":
+			err = t.Interval.UnmarshalFlag(val)
+			if err != nil {
+				return fmt.Errorf("invalid target interval: %w", err)
+			}
+		case "route":
+			t.Route = val
+		case "homepage":
+			t.Homepage = val
+		default:
+			return fmt.Errorf("unknown target option: %s", key)
+		}
+	}
+	return nil
+}
+
+func (t Target) MarshalFlag() (string, error) {
+	s := fmt.Sprintf("%d,interval=%s", t.UID, t.Interval)
+	if t.Route != "" {
+		s += ",route=" + t.Route
+	}
+	if t.Homepage != "" {
+		s += ",homepage=" + t.Homepage
+	}
+	return s, nil
+}
+
+// HomepageURL 获取刷新 Cookie 时访问的微博主页
+func (t Target) HomepageURL() string {
+	if t.Homepage != "" {
+		return t.Homepage
+	}
+	return fmt.Sprintf("https://weibo.com/u/%d", t.UID)
+}
+
+// Watcher 监控目标的轮询器
+type Watcher struct {
+	Target
+	key      string        // 通知路由对应的机器人类型
+	m        *Monitor      // 所属的监控
+	bot      *logrus.Entry // 绑定了通知路由的日志
+	log      *logrus.Entry // 带有目标字段的日志
+	polls    atomic.Int64  // 轮询次数
+	saved    atomic.Int64  // 保存博文数
+	failures atomic.Int64  // 失败次数
+	lastOK   atomic.Int64  // 上次轮询成功的时间戳
+
+	tracked     map[string]tracked // 时间线上出现过的博文，只在轮询协程中使用
+	followersAt time.Time          // 上次记录粉丝数量的时间，只在轮询协程中使用
+}
+
+// Status 获取轮询状态
+func (w *Watcher) Status() string {
+	last := "从未成功"
+	if ts := w.lastOK.Load(); ts != 0 {
+		last = time.Unix(ts, 0).Format(time.DateTime)
+	}
+	return fmt.Sprintf("轮询 %d 次 保存 %d 条 失败 %d 次 上次成功 %s", w.polls.Load(), w.saved.Load(), w.failures.Load(), last)
+}
+
+// Poll 获取一次微博并保存新博文
+func (w *Watcher) Poll(ctx context.Context) {
+	w.polls.Add(1)
+	ok := true
+	onError := func(err error) {
+		ok = false
+		w.failures.Add(1)
+		// 接口持续出错时只在统计窗口内通知一次
+		w.bot.WithFields(logrus.Fields{"title": "迭代微博出错", hook.Escalate: true}).Error(err)
+	}
+	var list []Mblog
+	for mblog := range GetMymlogIter(ctx, w.UID, w.m.Jar, onError) {
+		list = append(list, mblog)
+		blog := mblog.ToBlog()
+		// 当前博文未保存则写入数据库，会比较编辑次数是否有差异，如果有差异会重新写入
+		result := w.m.DB.Scopes(blog.Match).Limit(1).Find(&model.Blog{})
+		if result.Error != nil {
+			w.bot.WithField("title", "微博查询失败").Error(result.Error)
+			continue
+		}
+		// 已经保存过则跳过
+		if result.RowsAffected != 0 {
+			continue
+		}
+		// 否则展开长微博并补充博主信息
+		blog = w.expand(ctx, &mblog, blog)
+		w.m.Profiles.SetProfileInfo(ctx, blog, w.m.Jar)
+		// 查询同一博文的上一个版本，存在时视为编辑
+		prev, err := previous(w.m.DB, blog)
+		if err != nil {
+			w.bot.WithField("title", "微博查询失败").Error(err)
+		}
+		var sendBlog model.Blog
+		if prev != nil {
+			w.log.Infof("编辑微博: %s (第 %s 版 -> 第 %s 版)", blog, prev.Version, blog.Version)
+			blog.Extra["previous_id"] = prev.ID
+			sendBlog = *EditedBlog(prev, blog)
+		} else {
+			w.log.Infoln("保存微博:", blog)
+			sendBlog = *blog
+		}
+		// 异步通知
+		w.notify(ctx, &sendBlog)
+		// 写入数据库
+		err = w.m.DB.Create(blog).Error
+		if err != nil {
+			w.bot.WithField("title", "微博保存失败").Error(err)
+			continue
+		}
+		w.saved.Add(1)
+	}
+	if ok {
+		w.lastOK.Store(time.Now().Unix())
+		w.pollFollowers(ctx)
+		w.detectDeleted(ctx, list)
+	}
+}
+
+// previous 查询同一博文已保存的最新版本，只有普通博文会被视为编辑，不存在时返回空
+func previous(db *gorm.DB, blog *model.Blog) (*model.Blog, error) {
+	if blog.Type != "blog" {
+		return nil, nil
+	}
+	var prev model.Blog
+	result := db.Where("mid = ? AND site = ? AND type = ? AND uploader_id = ?", blog.MID, blog.Site, blog.Type, blog.UploaderID).
+		Order("id DESC").
+		Limit(1).
+		Find(&prev)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &prev, nil
+}
+
+// expand 展开被截断的长微博并重新转换，展开失败时仍使用截断的正文
+func (w *Watcher) expand(ctx context.Context, mblog *Mblog, blog *model.Blog) *model.Blog {
+	if !mblog.HasLongText() {
+		return blog
+	}
+	err := mblog.ExpandLongText(ctx, w.m.Jar)
+	if err != nil {
+		w.bot.WithField("title", "展开长微博失败").Error(err)
+		blog.Extra["longtext_error"] = err
+		return blog
+	}
+	return mblog.ToBlog()
+}
+
+// Run 按轮询间隔获取微博
+func (w *Watcher) Run(ctx context.Context) {
+	w.log.Infof("轮询获取微博 (间隔 %s)", w.Interval)
+	var now time.Time
+	last := time.Now()
+	fetchTicker := req.NewTicker(req.RandomTicker(w.Interval))
+	defer fetchTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info("停止轮询")
+			return
+		case now = <-fetchTicker.C:
+			w.log.Debugf("获取微博 (+%s)", now.Sub(last))
+			last = now
+			w.Poll(ctx)
+		}
+	}
+}
+
+// notify 异步发送通知，停止监控时不会取消正在发送的通知
+func (w *Watcher) notify(ctx context.Context, blog *model.Blog) {
+	ctx = context.WithoutCancel(ctx)
+	w.m.Go(func() { w.send(ctx, blog, w.m.Jar) })
+}
+
+// send 发送通知，存在匹配的路由规则时发送到规则的推送目标，否则通知路由为钉钉机器人时发送卡片，其余交给绑定的钩子发送
+func (w *Watcher) send(ctx context.Context, blog *model.Blog, jar http.CookieJar) {
+	typ := blog.Type
+	route := w.m.Routes.Match(w.UID, typ)
+	if blog.Type == "like" {
+		wrapper := &model.Blog{
+			UID:       strconv.Itoa(w.UID),
+			Avatar:    blog.Avatar,
+			URL:       blog.URL,
+			Time:      blog.Time,
+			Plaintext: blog.Title,
+			Extra:     model.Extra{},
+		}
+		w.m.Profiles.SetProfileInfo(ctx, wrapper, jar)
+		wrapper.Reply = blog
+		blog = wrapper
+	}
+	tmpl := w.m.Tmpl
+	if route != nil && route.Tmpl != nil {
+		tmpl = route.Tmpl
+	}
+	var b strings.Builder
+	msg := &Message{Target: w.UID, Type: typ, Title: blog.String(), Blog: blog}
+	err := tmpl.Execute(&b, blog)
+	if err != nil {
+		w.bot.WithField("title", "执行模板失败").Error(err)
+	} else {
+		msg.Text = b.String()
+	}
+	var sinks []Sink
+	switch {
+	case route != nil:
+		sinks = route.Sinks
+	case w.key == hook.DingTalk:
+		sinks = []Sink{DingTalkSink{Bot: w.m.Config.Logger.DingTalk}}
+	default:
+		w.bot.WithFields(logrus.Fields{"title": msg.Title, "url": blog.URL, "button": "阅读全文"}).Info(msg.Content())
+		return
+	}
+	for _, sink := range sinks {
+		err = sink.Send(ctx, msg)
+		if err != nil {
+			w.bot.WithFields(logrus.Fields{"title": "发送微博失败", "sink": sink.String()}).Error(err)
+		}
+	}
+}
+
+// NewWatcher 创建监控目标的轮询器，通知路由不存在时返回错误
+func NewWatcher(m *Monitor, target Target) (*Watcher, error) {
+	w := &Watcher{Target: target, m: m}
+	name := target.Route
+	if name == "" {
+		name = m.Config.Logger.Bot
+	}
+	var bot string
+	var ok bool
+	w.key, bot, ok = m.Config.Logger.Lookup(name)
+	if !ok && target.Route != "" {
+		return nil, fmt.Errorf("unknown route of target %d: %s", target.UID, target.Route)
+	}
+	fields := logrus.Fields{"target": target.UID}
+	w.log = m.Logger.WithFields(fields)
+	if ok {
+		fields[w.key] = bot
+	}
+	w.bot = m.Logger.WithFields(fields)
+	return w, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drelf2018/req"
+	"github.com/Drelf2018/req/cookie"
+)
+
+// 访客通行证请求成功时的返回码
+const VisitorRetcodeOK = 20000000
+
+// 生成访客时上报的浏览器指纹
+const visitorFingerprint = `{"os":"1","browser":"Chrome120,0,0,0","fonts":"undefined","screenInfo":"1920*1080*24","plugins":""}`
+
+// jsonp 解包 JSONP 响应中的 JSON 数据
+func jsonp(text string, v any) error {
+	start := strings.IndexByte(text, '(')
+	end := strings.LastIndexByte(text, ')')
+	if start == -1 || end < start {
+		return fmt.Errorf("invalid jsonp response: %q", text)
+	}
+	return json.Unmarshal([]byte(text[start+1:end]), v)
+}
+
+// GenVisitor 生成访客
+type GenVisitor struct {
+	req.PostForm
+	http.CookieJar
+
+	Cb string `req:"body" default:"gen_callback"`
+	Fp string `req:"body"` // 浏览器指纹
+}
+
+func (GenVisitor) RawURL() string {
+	return "/visitor/genvisitor"
+}
+
+var _ req.API = GenVisitor{}
+
+type GenVisitorResponse struct {
+	Retcode int    `json:"retcode"`
+	Msg     string `json:"msg"`
+	Data    struct {
+		Tid        string `json:"tid"`
+		NewTid     bool   `json:"new_tid"`
+		Confidence int    `json:"confidence"`
+	} `json:"data"`
+}
+
+func (r GenVisitorResponse) Unwrap() error {
+	if r.Retcode != VisitorRetcodeOK {
+		return fmt.Errorf("failed to generate visitor: %s (%d)", r.Msg, r.Retcode)
+	}
+	if r.Data.Tid == "" {
+		return errors.New("failed to generate visitor: empty tid")
+	}
+	return nil
+}
+
+var _ req.Unwrap = (*GenVisitorResponse)(nil)
+
+// Incarnate 将访客具象化，获取访客的 SUB 和 SUBP
+type Incarnate struct {
+	req.Get
+	http.CookieJar
+
+	A    string `req:"query" default:"incarnate"`
+	T    string `req:"query"` // 访客标识符
+	W    int    `req:"query"` // 新访客为 2 ，否则为 3
+	C    string `req:"query"` // 三位数的可信度
+	Gc   string `req:"query"` // 验证码，访客流程中为空
+	Cb   string `req:"query" default:"cross_domain"`
+	From string `req:"query" default:"weibo"`
+	Rand string `req:"query:_rand"` // 随机数，防止缓存
+}
+
+func (Incarnate) RawURL() string {
+	return "/visitor/visitor"
+}
+
+var _ req.API = Incarnate{}
+
+type IncarnateResponse struct {
+	Retcode int    `json:"retcode"`
+	Msg     string `json:"msg"`
+	Data    struct {
+		Sub  string `json:"sub"`
+		Subp string `json:"subp"`
+	} `json:"data"`
+}
+
+func (r IncarnateResponse) Unwrap() error {
+	if r.Retcode != VisitorRetcodeOK {
+		return fmt.Errorf("failed to incarnate visitor: %s (%d)", r.Msg, r.Retcode)
+	}
+	if r.Data.Sub == "" {
+		return errors.New("failed to incarnate visitor: empty sub")
+	}
+	return nil
+}
+
+var _ req.Unwrap = (*IncarnateResponse)(nil)
+
+// VisitorHome 访问微博首页，获取 XSRF-TOKEN
+type VisitorHome struct {
+	req.Get
+	http.CookieJar
+}
+
+func (VisitorHome) RawURL() string {
+	return "/"
+}
+
+var _ req.API = VisitorHome{}
+
+// Visitor 访客通行证配置，启用后不再需要浏览器
+type Visitor struct {
+	Enable   bool   `long:"enable" description:"使用访客通行证获取 Cookie ，不再启动浏览器"`
+	Passport string `long:"passport" default:"https://passport.weibo.com/" description:"访客通行证服务地址"`
+}
+
+// VisitorRefresher 使用访客通行证获取匿名 Cookie 的刷新器
+type VisitorRefresher struct {
+	Passport *req.Session                                // 访客通行证会话
+	Session  *req.Session                                // 微博会话，用于获取 XSRF-TOKEN
+	Validate func(context.Context, http.CookieJar) error // 校验 Cookie ，为空时不校验
+}
+
+// cookie 获取微博域名下的 Cookie
+func (v *VisitorRefresher) cookie(jar http.CookieJar, name string) string {
+	for _, c := range jar.Cookies(v.Session.BaseURL) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func (v *VisitorRefresher) IsValid(ctx context.Context, jar http.CookieJar) (bool, error) {
+	if v.cookie(jar, "SUB") == "" || v.cookie(jar, "XSRF-TOKEN") == "" {
+		return false, nil
+	}
+	if v.Validate == nil {
+		return true, nil
+	}
+	// 访客 Cookie 会在服务端失效，校验失败时视为需要刷新
+	return v.Validate(ctx, jar) == nil, nil
+}
+
+func (v *VisitorRefresher) Refresh(ctx context.Context, jar http.CookieJar) error {
+	// 生成访客
+	var gen GenVisitorResponse
+	text, err := v.Passport.TextWithContext(ctx, GenVisitor{CookieJar: jar, Fp: visitorFingerprint})
+	if err == nil {
+		err = jsonp(text, &gen)
+	}
+	if err == nil {
+		err = gen.Unwrap()
+	}
+	if err != nil {
+		return err
+	}
+	// 具象化访客
+	w, confidence := 3, gen.Data.Confidence
+	if gen.Data.NewTid {
+		w = 2
+	}
+	if confidence == 0 {
+		confidence = 100
+	}
+	var incarnate IncarnateResponse
+	text, err = v.Passport.TextWithContext(ctx, Incarnate{
+		CookieJar: jar,
+		T:         gen.Data.Tid,
+		W:         w,
+		C:         fmt.Sprintf("%03d", confidence),
+		Rand:      strconv.FormatFloat(float64(time.Now().UnixNano()%1e9)/1e9, 'f', -1, 64),
+	})
+	if err == nil {
+		err = jsonp(text, &incarnate)
+	}
+	if err == nil {
+		err = incarnate.Unwrap()
+	}
+	if err != nil {
+		return err
+	}
+	// 通行证服务地址可能不在微博域名下，手动设置 SUB 和 SUBP ，IP 地址不支持域 Cookie
+	var domain string
+	if host := v.Session.BaseURL.Hostname(); net.ParseIP(host) == nil {
+		domain = "." + host
+	}
+	jar.SetCookies(v.Session.BaseURL, []*http.Cookie{
+		{Name: "SUB", Value: incarnate.Data.Sub, Domain: domain, Path: "/"},
+		{Name: "SUBP", Value: incarnate.Data.Subp, Domain: domain, Path: "/"},
+	})
+	// 访问首页获取 XSRF-TOKEN
+	_, err = v.Session.ContentWithContext(ctx, VisitorHome{CookieJar: jar})
+	if err != nil {
+		return fmt.Errorf("failed to visit homepage: %w", err)
+	}
+	if v.cookie(jar, "XSRF-TOKEN") == "" {
+		return errors.New("missing XSRF-TOKEN")
+	}
+	if v.Validate != nil {
+		err = v.Validate(ctx, jar)
+		if err != nil {
+			return fmt.Errorf("invalid cookie: %w", err)
+		}
+	}
+	return nil
+}
+
+var _ cookie.Refresher = (*VisitorRefresher)(nil)
+
+// NewVisitorRefresher 创建访客通行证刷新器
+func NewVisitorRefresher(passport string, validate func(context.Context, http.CookieJar) error) *VisitorRefresher {
+	return &VisitorRefresher{
+		Passport: req.DefaultSession.Clone().SetBaseURL(passport).SetHeader(map[string]string{
+			"Referer": session.BaseURL.String(),
+		}),
+		Session:  session,
+		Validate: validate,
+	}
+}
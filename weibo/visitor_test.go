@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPassport 创建模拟的访客通行证服务，生成访客和具象化分别返回指定的返回码
+func newPassport(t *testing.T, genRetcode, incarnateRetcode string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/visitor/genvisitor":
+			if r.Method != http.MethodPost || r.PostFormValue("cb") != "gen_callback" || r.PostFormValue("fp") != visitorFingerprint {
+				t.Errorf("unexpected genvisitor request: %s %v", r.Method, r.PostForm)
+			}
+			w.Write([]byte(`window.gen_callback && gen_callback({"retcode":` + genRetcode + `,"msg":"gen","data":{"tid":"tid","new_tid":true,"confidence":95}});`))
+		case "/visitor/visitor":
+			q := r.URL.Query()
+			if q.Get("a") != "incarnate" || q.Get("t") != "tid" || q.Get("w") != "2" || q.Get("c") != "095" || q.Get("cb") != "cross_domain" {
+				t.Errorf("unexpected incarnate query: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`window.cross_domain && cross_domain({"retcode":` + incarnateRetcode + `,"msg":"incarnate","data":{"sub":"sub","subp":"subp"}});`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// visitorHome 访问首页时设置 XSRF-TOKEN
+func visitorHome(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "token", Path: "/"})
+	w.Write([]byte("<html></html>"))
+}
+
+func TestVisitorRefresh(t *testing.T) {
+	f := newFakeWeibo(t)
+	f.Handle("/", visitorHome)
+	f.JSON("/ajax/statuses/mymblog", map[string]any{"ok": 1})
+	passport := newPassport(t, "20000000", "20000000")
+	v := NewVisitorRefresher(passport.URL, ValidateMymlog(1))
+	jar, _ := cookiejar.New(nil)
+	ctx := context.Background()
+	if ok, _ := v.IsValid(ctx, jar); ok {
+		t.Fatal("empty jar should be invalid")
+	}
+	err := v.Refresh(ctx, jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := make(map[string]string)
+	for _, c := range jar.Cookies(session.BaseURL) {
+		cookies[c.Name] = c.Value
+	}
+	if cookies["SUB"] != "sub" || cookies["SUBP"] != "subp" || cookies["XSRF-TOKEN"] != "token" {
+		t.Errorf("unexpected cookies: %v", cookies)
+	}
+	// 校验时携带访客 Cookie 和 XSRF-TOKEN
+	r := f.Requests("/ajax/statuses/mymblog")
+	if len(r) != 1 || r[0].Header.Get("X-Xsrf-Token") != "token" {
+		t.Fatalf("cookie was not validated")
+	}
+	if c, err := r[0].Cookie("SUB"); err != nil || c.Value != "sub" {
+		t.Errorf("validation request missing SUB: %v", err)
+	}
+	if ok, err := v.IsValid(ctx, jar); !ok || err != nil {
+		t.Errorf("IsValid = %v, %v", ok, err)
+	}
+}
+
+func TestVisitorRefreshError(t *testing.T) {
+	f := newFakeWeibo(t)
+	for _, c := range []struct {
+		name       string
+		gen        string
+		incarnate  string
+		home       http.HandlerFunc
+		wantSubstr string
+	}{
+		{"genvisitor", "50000000", "20000000", visitorHome, "failed to generate visitor: gen (50000000)"},
+		{"incarnate", "20000000", "50000000", visitorHome, "failed to incarnate visitor: incarnate (50000000)"},
+		{"xsrf", "20000000", "20000000", func(w http.ResponseWriter, r *http.Request) {}, "missing XSRF-TOKEN"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f.Handle("/", c.home)
+			v := NewVisitorRefresher(newPassport(t, c.gen, c.incarnate).URL, nil)
+			jar, _ := cookiejar.New(nil)
+			err := v.Refresh(context.Background(), jar)
+			if err == nil || !strings.Contains(err.Error(), c.wantSubstr) {
+				t.Errorf("error = %v, want %q", err, c.wantSubstr)
+			}
+		})
+	}
+}
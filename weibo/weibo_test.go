@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// fakeWeibo 模拟微博接口的测试服务器，测试期间会替换全局会话的地址
+type fakeWeibo struct {
+	*httptest.Server
+	t        *testing.T
+	mu       sync.Mutex
+	handlers map[string]http.HandlerFunc
+	requests []*http.Request
+}
+
+func newFakeWeibo(t *testing.T) *fakeWeibo {
+	f := &fakeWeibo{t: t, handlers: make(map[string]http.HandlerFunc)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r)
+		handler, ok := f.handlers[r.URL.Path]
+		f.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	old := session
+	session = session.Clone().SetBaseURL(f.URL)
+	t.Cleanup(func() {
+		session = old
+		f.Close()
+	})
+	return f
+}
+
+// Handle 设置接口的处理函数
+func (f *fakeWeibo) Handle(path string, handler http.HandlerFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[path] = handler
+}
+
+// JSON 设置接口返回固定的 JSON
+func (f *fakeWeibo) JSON(path string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.Handle(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+// Requests 获取指定接口收到的请求
+func (f *fakeWeibo) Requests(path string) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var r []*http.Request
+	for _, req := range f.requests {
+		if req.URL.Path == path {
+			r = append(r, req)
+		}
+	}
+	return r
+}
+
+// Jar 创建带有 XSRF-TOKEN 的 Cookie
+func (f *fakeWeibo) Jar() http.CookieJar {
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse(f.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "XSRF-TOKEN", Value: "token"}})
+	return jar
+}
+
+// chanSink 将通知写入通道的推送目标
+type chanSink chan *Message
+
+func (c chanSink) Send(ctx context.Context, msg *Message) error {
+	c <- msg
+	return nil
+}
+
+func (chanSink) String() string {
+	return "chan"
+}